@@ -6,13 +6,19 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	h "github.com/hajimeo/samples/golang/helpers"
 	"github.com/pkg/errors"
 	"io"
+	"net/http"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -23,8 +29,17 @@ import (
 
 type AzClient struct {
 	ClientNum int
+	// Container is used instead of common.Container if set (eg. the group members checked concurrently)
+	Container string
 }
 
+// AZ_VERSION_QUERY is appended to the blob name of the previous version (same as the version URL)
+const AZ_VERSION_QUERY = "?versionid="
+
+// BlobInfo.State values with -azDeleted
+const AZ_STATE_DELETED = "deleted"
+const AZ_STATE_VERSION = "version"
+
 var AzApi *azblob.Client
 var AzApi2 *azblob.Client
 var AzContainer *container.Client
@@ -77,7 +92,9 @@ func getAzApi(clientNum int) *azblob.Client {
 	}
 	var err error
 	var maybeAzApi *azblob.Client
-	maybeAzApi, err = azblob.NewClientFromConnectionString(connStr, nil)
+	maybeAzApi, err = azblob.NewClientFromConnectionString(connStr, &azblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{PerCallPolicies: []policy.Policy{azMetricsPolicy{}}, PerRetryPolicies: []policy.Policy{azRateLimitPolicy{}}},
+	})
 	if err != nil {
 		panic("configuration error, " + err.Error())
 	}
@@ -89,6 +106,40 @@ func getAzApi(clientNum int) *azblob.Client {
 	return AzApi
 }
 
+// azMetricsPolicy records the latency of each Azure API call (including retries)
+type azMetricsPolicy struct{}
+
+func (p azMetricsPolicy) Do(req *policy.Request) (*http.Response, error) {
+	startMs := time.Now().UnixMilli()
+	resp, err := req.Next()
+	// The operation is the HTTP method with the 'comp' query (eg. 'GET_list', 'PUT_tags')
+	operation := req.Raw().Method
+	if comp := req.Raw().URL.Query().Get("comp"); len(comp) > 0 {
+		operation += "_" + comp
+	}
+	errForMetrics := err
+	if err == nil && resp != nil && resp.StatusCode >= 400 {
+		// Same as S3, counting 4xx (eg. 404 Not Found) as the error
+		errForMetrics = errors.New(resp.Status)
+	}
+	lib.ObserveRequest("az", operation, startMs, errForMetrics)
+	return resp, err
+}
+
+// azRateLimitPolicy waits for -rps per attempt (so retries are also limited), and back-off when throttled (503 ServerBusy, 429)
+type azRateLimitPolicy struct{}
+
+func (p azRateLimitPolicy) Do(req *policy.Request) (*http.Response, error) {
+	lib.WaitRequest()
+	resp, err := req.Next()
+	if resp != nil && (resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusTooManyRequests) {
+		lib.Throttled("az")
+	} else if err == nil && resp != nil && resp.StatusCode < 400 {
+		lib.Succeeded()
+	}
+	return resp, err
+}
+
 func decideContainer(clientNum int) string {
 	if clientNum == 2 {
 		return common.Container2
@@ -140,12 +191,67 @@ func getAzContainer(clientNum int) *container.Client {
 	return AzContainer
 }
 
-func getAzObject(path string, clientNum int) (azblob.DownloadStreamResponse, error) {
-	return getAzApi(clientNum).DownloadStream(context.TODO(), decideContainer(clientNum), path, nil)
+// SplitAzVersion splits the path into the blob name and the version ID (empty if the current version)
+func SplitAzVersion(path string) (string, string) {
+	if idx := strings.LastIndex(path, AZ_VERSION_QUERY); idx > 0 {
+		return path[:idx], path[idx+len(AZ_VERSION_QUERY):]
+	}
+	return path, ""
+}
+
+func (a *AzClient) containerName() string {
+	if len(a.Container) > 0 {
+		return a.Container
+	}
+	return decideContainer(a.ClientNum)
+}
+
+// getContainer returns the cached container client, or the new one if Container is set (no request is made)
+func (a *AzClient) getContainer() *container.Client {
+	if len(a.Container) > 0 {
+		return getAzApi(a.ClientNum).ServiceClient().NewContainerClient(a.Container)
+	}
+	return getAzContainer(a.ClientNum)
+}
+
+// getBlobClient returns the blob client, or the client of the version if the path ends with AZ_VERSION_QUERY + ID
+func (a *AzClient) getBlobClient(path string) (*blob.Client, error) {
+	name, versionId := SplitAzVersion(path)
+	blobClient := a.getContainer().NewBlobClient(name)
+	if len(versionId) == 0 {
+		return blobClient, nil
+	}
+	return blobClient.WithVersionID(versionId)
+}
+
+func (a *AzClient) getObject(path string) (blob.DownloadStreamResponse, error) {
+	blobClient, err := a.getBlobClient(path)
+	if err != nil {
+		return blob.DownloadStreamResponse{}, err
+	}
+	return blobClient.DownloadStream(context.TODO(), nil)
 }
 
-func setAzObject(path string, contents string, clientNum int) (azblob.UploadStreamResponse, error) {
-	return getAzApi(clientNum).UploadStream(context.TODO(), decideContainer(clientNum), path, strings.NewReader(contents), nil)
+func (a *AzClient) setObject(path string, contents string) (azblob.UploadStreamResponse, error) {
+	return getAzApi(a.ClientNum).UploadStream(context.TODO(), a.containerName(), path, strings.NewReader(contents), nil)
+}
+
+// IsRetryable : Retrying 5xx, 408, 429 and the errors without HTTP response (eg. connection reset). Not retrying 404 etc.
+func (a *AzClient) IsRetryable(err error) bool {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return isRetryableStatus(respErr.StatusCode)
+	}
+	return true
+}
+
+// IsNotFound : BlobNotFound (or 404 without the error code, eg. HEAD)
+func (a *AzClient) IsNotFound(err error) bool {
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound && respErr.ErrorCode != string(bloberror.ContainerNotFound)
 }
 
 func (a *AzClient) ReadPath(path string) (string, error) {
@@ -154,13 +260,13 @@ func (a *AzClient) ReadPath(path string) (string, error) {
 	} else {
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file read for path:"+path, common.SlowMS*2)
 	}
-	resp, err := getAzObject(path, a.ClientNum)
+	resp, err := a.getObject(path)
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("getAzObject for %s failed with %s.", path, err.Error()))
 		return "", err
 	}
 	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(resp.Body)
+	_, err = buf.ReadFrom(lib.LimitReadCloser(resp.Body))
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("ReadFrom for %s failed with %s.", path, err.Error()))
 		return "", err
@@ -176,7 +282,8 @@ func (a *AzClient) WriteToPath(path string, contents string) error {
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file write for path:"+path, common.SlowMS*2)
 	}
 
-	resp, err := setAzObject(path, contents, a.ClientNum)
+	lib.WaitBytes(len(contents))
+	resp, err := a.setObject(path, contents)
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("Path: %s. Resp: %v", path, resp))
 		return err
@@ -185,12 +292,12 @@ func (a *AzClient) WriteToPath(path string, contents string) error {
 }
 
 func (a *AzClient) GetReader(path string) (interface{}, error) {
-	inFile, err := getAzObject(path, a.ClientNum)
+	inFile, err := a.getObject(path)
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("GetReader: %s failed with %s.", path, err.Error()))
 		return nil, err
 	}
-	return inFile.Body, nil
+	return lib.LimitReadCloser(inFile.Body), nil
 }
 
 func (a *AzClient) GetWriter(path string) (interface{}, error) {
@@ -198,7 +305,7 @@ func (a *AzClient) GetWriter(path string) (interface{}, error) {
 	// TODO: error handling. If copy filed, it doesn't return any error
 	pr, pw := io.Pipe()
 	go func() {
-		_, err := getAzApi(a.ClientNum).UploadStream(context.TODO(), decideContainer(a.ClientNum), path, pr, nil)
+		_, err := getAzApi(a.ClientNum).UploadStream(context.TODO(), a.containerName(), path, pr, nil)
 		if err != nil {
 			h.Log("ERROR", fmt.Sprintf("GetWriter: UploadStream for %s failed with %s.", path, err.Error()))
 			_ = pr.CloseWithError(err)
@@ -222,14 +329,14 @@ func (a *AzClient) GetPath(path string, localPath string) error {
 	}
 	defer outFile.Close()
 
-	inFile, err := getAzObject(path, a.ClientNum)
+	inFile, err := a.getObject(path)
 	if err != nil {
 		err2 := fmt.Errorf("getAzObject for %s failed with %s", path, err.Error())
 		return err2
 	}
 	defer inFile.Body.Close()
 
-	bytesWritten, err := io.Copy(outFile, inFile.Body)
+	bytesWritten, err := io.Copy(outFile, lib.LimitReadCloser(inFile.Body))
 	if err != nil {
 		err2 := fmt.Errorf("failed to copy path: %s into %s with error: %s", path, localPath, err.Error())
 		return err2
@@ -263,6 +370,95 @@ func (a *AzClient) RemoveDeleted(path string, contents string) error {
 	return a.WriteToPath(path, updatedContents)
 }
 
+// GetTags returns the blob index tags
+func (a *AzClient) GetTags(path string) (map[string]string, error) {
+	blobClient, err := a.getBlobClient(path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := blobClient.GetTags(context.TODO(), nil)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range resp.BlobTagSet {
+		if tag != nil && tag.Key != nil && tag.Value != nil {
+			tags[*tag.Key] = *tag.Value
+		}
+	}
+	return tags, nil
+}
+
+// SetTags replaces all blob index tags
+func (a *AzClient) SetTags(path string, tags map[string]string) error {
+	blobClient, err := a.getBlobClient(path)
+	if err != nil {
+		return err
+	}
+	_, err = blobClient.SetTags(context.TODO(), tags, nil)
+	return err
+}
+
+// Undelete undeletes the soft-deleted blob, and/or copies the previous version to the current blob.
+// The version is copied only when the current blob does not exist and it is the latest previous version. Returns what was done.
+func (a *AzClient) Undelete(bi BlobInfo) (string, error) {
+	name, versionId := SplitAzVersion(bi.Path)
+	baseClient := a.getContainer().NewBlobClient(name)
+	result := ""
+	if bi.State == AZ_STATE_DELETED {
+		// This also undeletes the soft-deleted versions of this blob
+		if _, err := baseClient.Undelete(context.TODO(), nil); err != nil {
+			return result, err
+		}
+		result = "UNDELETED"
+	}
+	if len(versionId) == 0 {
+		return result, nil
+	}
+	if _, err := baseClient.GetProperties(context.TODO(), nil); err == nil {
+		// Not overwriting the current blob
+		return strings.TrimPrefix(result+"|CURRENT_EXISTS", "|"), nil
+	} else if !bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return result, err
+	}
+	latestVersionId, err := a.latestVersionId(name)
+	if err != nil {
+		return result, err
+	}
+	if latestVersionId != versionId {
+		return strings.TrimPrefix(result+"|NOT_LATEST_VERSION", "|"), nil
+	}
+	versionClient, err := baseClient.WithVersionID(versionId)
+	if err != nil {
+		return result, err
+	}
+	if _, err = baseClient.StartCopyFromURL(context.TODO(), versionClient.URL(), nil); err != nil {
+		return result, err
+	}
+	return strings.TrimPrefix(result+"|RESTORED_VERSION", "|"), nil
+}
+
+// latestVersionId returns the newest version ID of the blob (the version IDs are the timestamps, so comparable as string)
+func (a *AzClient) latestVersionId(name string) (string, error) {
+	latest := ""
+	pager := a.getContainer().NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+		Include: container.ListBlobsInclude{Versions: true},
+		Prefix:  to.Ptr(name),
+	})
+	for pager.More() {
+		resp, err := pager.NextPage(context.TODO())
+		if err != nil {
+			return "", err
+		}
+		for _, item := range resp.Segment.BlobItems {
+			if *item.Name == name && item.VersionID != nil && *item.VersionID > latest {
+				latest = *item.VersionID
+			}
+		}
+	}
+	return latest, nil
+}
+
 func (a *AzClient) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]string, error) {
 	if common.Debug {
 		defer h.Elapsed(time.Now().UnixMilli(), "Walked "+baseDir, int64(0))
@@ -291,7 +487,7 @@ func (a *AzClient) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]s
 		MaxResults: to.Ptr(int32(common.MaxKeys)),
 		Prefix:     to.Ptr(prefix + "/"),
 	}
-	pager := getAzContainer(a.ClientNum).NewListBlobsHierarchyPager("/", &opts)
+	pager := a.getContainer().NewListBlobsHierarchyPager("/", &opts)
 	for pager.More() {
 		resp, err := pager.NextPage(context.TODO())
 		if err != nil {
@@ -330,20 +526,21 @@ func (a *AzClient) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]s
 	return matchingDirs, nil
 }
 
-func (a *AzClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) int64 {
+func (a *AzClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) (int64, error) {
 	// ListObjects: List all files in one directory recursively.
 	// Global variables should be only TopN, PrintedNum
 	var subTtl int64
+	var listErr error
 	prefix := h.AppendSlash(dir)
 
 	// Walk through the directory structure
 	opts := container.ListBlobsFlatOptions{
-		//Include:    container.ListBlobsInclude{Versions: true},
-		//Marker:     nil,
+		// The metadata and the index tags are included in the list response, so no extra request per blob
+		Include:    container.ListBlobsInclude{Metadata: common.WithOwner || common.WithTags, Tags: common.WithTags, Deleted: common.AzDeleted, Versions: common.AzDeleted},
 		MaxResults: to.Ptr(int32(common.MaxKeys)),
 		Prefix:     to.Ptr(prefix),
 	}
-	pager := getAzContainer(a.ClientNum).NewListBlobsFlatPager(&opts)
+	pager := a.getContainer().NewListBlobsFlatPager(&opts)
 	for pager.More() {
 		if common.TopN > 0 && common.TopN <= common.PrintedNum {
 			h.Log("DEBUG", fmt.Sprintf("Printed %d >= %d", common.PrintedNum, common.TopN))
@@ -352,15 +549,21 @@ func (a *AzClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLin
 		resp, err := pager.NextPage(context.TODO())
 		if err != nil {
 			h.Log("ERROR", "Got error: "+err.Error()+" from "+dir)
+			listErr = err
 			break
 		}
 
 		// Process virtual directories (directories) and blobs
 		for _, blob := range resp.Segment.BlobItems {
+			// Listing only the soft-deleted blobs and the previous versions
+			if common.AzDeleted && len(azState(blob)) == 0 {
+				continue
+			}
 			subTtl++
+			bInfo := a.Convert2BlobInfo(blob)
 			args := PrintLineArgs{
-				Path:    *blob.Name,
-				BInfo:   a.Convert2BlobInfo(blob),
+				Path:    bInfo.Path,
+				BInfo:   bInfo,
 				DB:      db,
 				SaveDir: dir,
 			}
@@ -369,26 +572,53 @@ func (a *AzClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLin
 			}
 		}
 	}
-	return subTtl
+	return subTtl, listErr
+}
+
+// CreateNewPath : Same as WriteToPath, but with 'If-None-Match: *' to never overwrite the existing blob
+func (a *AzClient) CreateNewPath(path string, contents string) error {
+	lib.WaitBytes(len(contents))
+	opts := &azblob.UploadStreamOptions{AccessConditions: &blob.AccessConditions{
+		ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+	}}
+	_, err := getAzApi(a.ClientNum).UploadStream(context.TODO(), a.containerName(), path, strings.NewReader(contents), opts)
+	if bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+		return ErrPathExists
+	}
+	return err
 }
 
 func (a *AzClient) GetFileInfo(name string) (BlobInfo, error) {
 	// Get one BlobItem from Azure container
-	blobClient := getAzContainer(a.ClientNum).NewBlobClient(name)
+	blobClient, err := a.getBlobClient(name)
+	if err != nil {
+		return BlobInfo{Error: true}, err
+	}
 	blobItemProps, err := blobClient.GetProperties(context.Background(), nil)
 	if err != nil {
 		return BlobInfo{Error: true}, err
 	}
 	owner := ""
-
-	if blobItemProps.Metadata != nil {
-		owner = *blobItemProps.Metadata["owner"]
+	if v, ok := blobItemProps.Metadata["owner"]; ok && v != nil {
+		owner = *v
+	}
+	tags := ""
+	if common.WithTags {
+		var indexTags map[string]string
+		if blobItemProps.TagCount != nil && *blobItemProps.TagCount > 0 {
+			indexTags, err = a.GetTags(name)
+			if err != nil {
+				h.Log("WARN", fmt.Sprintf("GetTags for %s failed with %v", name, err))
+			}
+		}
+		tags = azTagsJson(blobItemProps.Metadata, indexTags)
 	}
 	blobInfo := BlobInfo{
 		Path:    name,
 		ModTime: *blobItemProps.LastModified,
 		Size:    *blobItemProps.ContentLength, // TODO: this may not be correct if .bytes
-		Owner:   owner,                        // TODO: this is not working
+		Owner:   owner,
+		Tags:    tags,
 	}
 	return blobInfo, nil
 }
@@ -398,12 +628,68 @@ func (a *AzClient) Convert2BlobInfo(f interface{}) BlobInfo {
 	owner := ""
 	if item.Properties.Owner != nil {
 		owner = *item.Properties.Owner
+	} else if v, ok := item.Metadata["owner"]; ok && v != nil {
+		owner = *v
+	}
+	tags := ""
+	if common.WithTags {
+		indexTags := make(map[string]string)
+		if item.BlobTags != nil {
+			for _, tag := range item.BlobTags.BlobTagSet {
+				if tag != nil && tag.Key != nil && tag.Value != nil {
+					indexTags[*tag.Key] = *tag.Value
+				}
+			}
+		}
+		tags = azTagsJson(item.Metadata, indexTags)
+	}
+	path := *item.Name
+	state := azState(item)
+	if len(state) > 0 && item.VersionID != nil {
+		path = path + AZ_VERSION_QUERY + *item.VersionID
 	}
 	blobInfo := BlobInfo{
-		Path:    *item.Name,
+		Path:    path,
 		ModTime: *item.Properties.LastModified,
 		Size:    *item.Properties.ContentLength, // TODO: this may not be correct
 		Owner:   owner,
+		Tags:    tags,
+		State:   state,
+		// The soft-deleted blob can not be read until undeleted
+		Error: state == AZ_STATE_DELETED,
 	}
 	return blobInfo
 }
+
+// azState returns AZ_STATE_DELETED for the soft-deleted blob, AZ_STATE_VERSION for the previous version, or empty for the current blob
+func azState(item *container.BlobItem) string {
+	if item.Deleted != nil && *item.Deleted {
+		return AZ_STATE_DELETED
+	}
+	// IsCurrentVersion is only set (true) for the current version
+	if item.VersionID != nil && (item.IsCurrentVersion == nil || !*item.IsCurrentVersion) {
+		return AZ_STATE_VERSION
+	}
+	return ""
+}
+
+// azTagsJson returns the metadata and the index tags as one JSON object (empty if none of them)
+func azTagsJson(metadata map[string]*string, indexTags map[string]string) string {
+	tags := make(map[string]map[string]string)
+	if len(metadata) > 0 {
+		tags["Metadata"] = make(map[string]string)
+		for k, v := range metadata {
+			if v != nil {
+				tags["Metadata"][k] = *v
+			}
+		}
+	}
+	if len(indexTags) > 0 {
+		tags["Tags"] = indexTags
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	jsonTags, _ := json.Marshal(tags)
+	return string(jsonTags)
+}
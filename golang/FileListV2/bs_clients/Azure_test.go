@@ -2,13 +2,20 @@ package bs_clients
 
 import (
 	"FileListV2/common"
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	h "github.com/hajimeo/samples/golang/helpers"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAzClient(t *testing.T) {
@@ -180,12 +187,12 @@ func TestListObjects_Azure(t *testing.T) {
 	}
 
 	common.PrintedNum = 0
-	subTtl := azClient.ListObjects("", db, testFunc)
+	subTtl, _ := azClient.ListObjects("", db, testFunc)
 	t.Logf("subTtl: %v", subTtl)
 	assert.Greater(t, subTtl, int64(0))
 
 	common.PrintedNum = 0
-	subTtl = azClient.ListObjects("content", db, testFunc)
+	subTtl, _ = azClient.ListObjects("content", db, testFunc)
 	t.Logf("subTtl under content: %v", subTtl)
 	assert.Greater(t, subTtl, int64(0))
 }
@@ -212,3 +219,171 @@ func TestGetPath_ValidPathAndLocalPath_CopiesFileSuccessfully(t *testing.T) {
 	//t.Logf("content: %s", string(bytes))
 	os.Remove(localPath)
 }
+
+func TestSplitAzVersion_WithAndWithoutVersion(t *testing.T) {
+	name, versionId := SplitAzVersion("content/vol-01/chap-01/a.properties" + AZ_VERSION_QUERY + "2024-01-02T03:04:05.1234567Z")
+	assert.Equal(t, "content/vol-01/chap-01/a.properties", name)
+	assert.Equal(t, "2024-01-02T03:04:05.1234567Z", versionId)
+
+	name, versionId = SplitAzVersion("content/vol-01/chap-01/a.properties")
+	assert.Equal(t, "content/vol-01/chap-01/a.properties", name)
+	assert.Equal(t, "", versionId)
+}
+
+func TestConvert2BlobInfo_DeletedAndVersion_SetsState(t *testing.T) {
+	common.WithTags = true
+	defer func() { common.WithTags = false }()
+	now := time.Now()
+	item := &container.BlobItem{
+		Name:       to.Ptr("content/vol-01/chap-01/a.properties"),
+		Properties: &container.BlobProperties{LastModified: &now, ContentLength: to.Ptr(int64(10))},
+		Metadata:   map[string]*string{"owner": to.Ptr("nexus")},
+		BlobTags:   &container.BlobTags{BlobTagSet: []*container.BlobTag{{Key: to.Ptr("deleted"), Value: to.Ptr("true")}}},
+	}
+	azClient := AzClient{}
+	bi := azClient.Convert2BlobInfo(item)
+	assert.Equal(t, "", bi.State)
+	assert.Equal(t, "nexus", bi.Owner)
+	assert.Equal(t, `{"Metadata":{"owner":"nexus"},"Tags":{"deleted":"true"}}`, bi.Tags)
+	assert.False(t, bi.Error)
+
+	// Previous version: IsCurrentVersion is not set
+	item.VersionID = to.Ptr("2024-01-02T03:04:05.1234567Z")
+	bi = azClient.Convert2BlobInfo(item)
+	assert.Equal(t, AZ_STATE_VERSION, bi.State)
+	assert.Equal(t, "content/vol-01/chap-01/a.properties"+AZ_VERSION_QUERY+"2024-01-02T03:04:05.1234567Z", bi.Path)
+
+	item.IsCurrentVersion = to.Ptr(true)
+	bi = azClient.Convert2BlobInfo(item)
+	assert.Equal(t, "", bi.State)
+	assert.Equal(t, "content/vol-01/chap-01/a.properties", bi.Path)
+
+	// Soft-deleted blob can not be read
+	item.VersionID = nil
+	item.Deleted = to.Ptr(true)
+	bi = azClient.Convert2BlobInfo(item)
+	assert.Equal(t, AZ_STATE_DELETED, bi.State)
+	assert.True(t, bi.Error)
+}
+
+func TestGetContainer_EmptyContainer_UsesContainerPerClientNum_Azure(t *testing.T) {
+	// No request is made, so Azurite is not required
+	connStr := "DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=" + AZURITE_ACCOUNT_KEY + ";BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;"
+	var err error
+	AzApi, err = azblob.NewClientFromConnectionString(connStr, nil)
+	assert.NoError(t, err)
+	AzApi2, err = azblob.NewClientFromConnectionString(connStr, nil)
+	assert.NoError(t, err)
+	common.Container, common.Container2 = "container-a", "container-b"
+	AzContainer, AzContainer2 = nil, nil
+	defer func() {
+		AzApi, AzApi2, AzContainer, AzContainer2 = nil, nil, nil, nil
+		common.Container, common.Container2 = "", ""
+	}()
+
+	client := &AzClient{}
+	assert.Equal(t, "container-a", client.containerName())
+	assert.True(t, strings.HasSuffix(client.getContainer().URL(), "/container-a"))
+	assert.Equal(t, AzContainer, client.getContainer())
+	client2 := &AzClient{}
+	client2.SetClientNum(2)
+	assert.Equal(t, "container-b", client2.containerName())
+	assert.True(t, strings.HasSuffix(client2.getContainer().URL(), "/container-b"))
+	assert.Equal(t, AzContainer2, client2.getContainer())
+
+	// useMember() resets the cached container client for the next member
+	common.Container, AzContainer = "container-c", nil
+	assert.True(t, strings.HasSuffix(client.getContainer().URL(), "/container-c"))
+	// The explicit Container is used regardless of ClientNum
+	member := &AzClient{Container: "container-d"}
+	assert.True(t, strings.HasSuffix(member.getContainer().URL(), "/container-d"))
+}
+
+// AZURITE_ACCOUNT_KEY is the well-known key of the Azurite account (devstoreaccount1)
+const AZURITE_ACCOUNT_KEY = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// useAzurite points the client to Azurite (default: 127.0.0.1:10000 with the well-known account) and creates a new container.
+// Skipped if Azurite is not running.
+func useAzurite(t *testing.T) {
+	endpoint := h.GetEnv("AZURITE_BLOB_ENDPOINT", "http://127.0.0.1:10000/devstoreaccount1")
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		t.Skipf("Azurite is not running on %s", u.Host)
+	}
+	_ = conn.Close()
+	accountName := "devstoreaccount1"
+	accountKey := AZURITE_ACCOUNT_KEY
+	t.Setenv("AZURE_STORAGE_ACCOUNT_NAME", accountName)
+	t.Setenv("AZURE_STORAGE_ACCOUNT_KEY", accountKey)
+	t.Setenv("AZURE_STORAGE_CONNECTION_STRING", "DefaultEndpointsProtocol=http;AccountName="+accountName+";AccountKey="+accountKey+";BlobEndpoint="+endpoint+";")
+	common.Container = fmt.Sprintf("filelist2-test-%d", time.Now().UnixNano())
+	AzApi = nil
+	AzContainer = nil
+	if _, err = getAzContainer(1).Create(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_, _ = getAzContainer(1).Delete(context.TODO(), nil)
+		AzApi = nil
+		AzContainer = nil
+		common.Container = ""
+	})
+}
+
+func TestTags_SetAndGet_Azurite(t *testing.T) {
+	useAzurite(t)
+	azClient := AzClient{}
+	path := "content/vol-01/chap-01/a.properties"
+	assert.NoError(t, azClient.WriteToPath(path, "size=1"))
+	_, err := getAzContainer(1).NewBlobClient(path).SetMetadata(context.TODO(), map[string]*string{"owner": to.Ptr("nexus")}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, azClient.SetTags(path, map[string]string{"deleted": "true"}))
+
+	tags, err := azClient.GetTags(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"deleted": "true"}, tags)
+	_, ok := AsTagClient(WithRetry(&azClient))
+	assert.True(t, ok)
+
+	// The metadata and the index tags are included in the listing with -T
+	common.WithTags = true
+	common.WithOwner = true
+	defer func() {
+		common.WithTags = false
+		common.WithOwner = false
+	}()
+	var infos []BlobInfo
+	azClient.ListObjects("content", nil, func(args PrintLineArgs) bool {
+		infos = append(infos, args.BInfo)
+		return true
+	})
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "nexus", infos[0].Owner)
+	assert.Equal(t, `{"Metadata":{"owner":"nexus"},"Tags":{"deleted":"true"}}`, infos[0].Tags)
+
+	bi, err := azClient.GetFileInfo(path)
+	assert.NoError(t, err)
+	assert.Equal(t, infos[0].Tags, bi.Tags)
+}
+
+func TestUndelete_SoftDeleted_Azurite(t *testing.T) {
+	useAzurite(t)
+	azClient := AzClient{}
+	path := "content/vol-01/chap-01/b.properties"
+	assert.NoError(t, azClient.WriteToPath(path, "size=1"))
+	if _, err := getAzContainer(1).NewBlobClient(path).Delete(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+	result, err := azClient.Undelete(BlobInfo{Path: path, State: AZ_STATE_DELETED})
+	if err != nil {
+		t.Skipf("Undelete is not supported (soft delete is not enabled): %s", err.Error())
+	}
+	assert.Equal(t, "UNDELETED", result)
+	contents, err := azClient.ReadPath(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "size=1", contents)
+}
@@ -14,7 +14,8 @@ type Client interface {
 	// GetDirs : Get the directories in the path
 	GetDirs(string, string, int) ([]string, error)
 	// ListObjects : List the objects in the path. NOT recursive or check WalkRecursive. DB is used in the func
-	ListObjects(string, *sql.DB, func(PrintLineArgs) bool) int64
+	// Returns the error if the listing stopped in the middle (not returned when stopped by perLineFunc or TopN)
+	ListObjects(string, *sql.DB, func(PrintLineArgs) bool) (int64, error)
 	// ReadPath : Read the contents of the file path
 	ReadPath(string) (string, error)
 	// WriteToPath : Write the contents to the file path
@@ -35,6 +36,51 @@ type Client interface {
 	SetClientNum(int)
 }
 
+// TagClient : Optional interface for the clients which use the object tags (eg. S3). Used to restore the tags with -undo
+type TagClient interface {
+	// GetTags : Get the tags of the path as the map
+	GetTags(string) (map[string]string, error)
+	// SetTags : Replace all tags of the path
+	SetTags(string, map[string]string) error
+}
+
+// UndeleteClient : Optional interface for the clients which can restore the blobs deleted by the storage itself (eg. Azure soft delete)
+type UndeleteClient interface {
+	// Undelete : Restore the listed blob (BlobInfo.State). Returns what was done
+	Undelete(BlobInfo) (string, error)
+}
+
+// NotFoundChecker : Optional interface for the clients to decide if the error means the path does not exist (not 403, 5xx, I/O error etc.)
+type NotFoundChecker interface {
+	IsNotFound(error) bool
+}
+
+// CreateOnlyWriter : Optional interface for the clients which can write the path only when it does not exist (never overwrite)
+type CreateOnlyWriter interface {
+	// CreateNewPath : Same as WriteToPath, but returns ErrPathExists if the path already exists
+	CreateNewPath(string, string) error
+}
+
+// ErrPathExists is returned by CreateNewPath when the path already exists
+var ErrPathExists = errors.New("path already exists")
+
+// IsNotFound returns true only if the client decides the error is "not found". Unknown errors are not "not found".
+func IsNotFound(client Client, err error) bool {
+	if err == nil {
+		return false
+	}
+	if checker, ok := Unwrap(client).(NotFoundChecker); ok {
+		return checker.IsNotFound(err)
+	}
+	return false
+}
+
+// AsCreateOnlyWriter returns the CreateOnlyWriter if the (original) client supports the create-only write
+func AsCreateOnlyWriter(client Client) (CreateOnlyWriter, bool) {
+	writer, ok := Unwrap(client).(CreateOnlyWriter)
+	return writer, ok
+}
+
 type BlobInfo struct {
 	Path    string
 	ModTime time.Time
@@ -43,6 +89,7 @@ type BlobInfo struct {
 	Tags    string // JSON string
 	BlobRef string
 	Note    string
+	State   string // Azure: AZ_STATE_DELETED or AZ_STATE_VERSION with -azDeleted
 	Error   bool
 }
 
@@ -61,8 +108,7 @@ func GetClient(bsType string) Client {
 		return &AzClient{}
 	}
 	if bsType == "gs" {
-		// TODO: add this type (gs)
-		panic(bsType + " is currently not supported yet")
+		return &GsClient{}
 	}
 	// Default is FileClient
 	if bsType == "file" || bsType == "" {
@@ -71,6 +117,19 @@ func GetClient(bsType string) Client {
 	panic("Unknown type: " + bsType + ".")
 }
 
+// GetClientWithContainer returns the client which uses the container (bucket) instead of common.Container, so that multiple clients for different containers can be used at the same time
+func GetClientWithContainer(bsType string, container string) Client {
+	switch bsType {
+	case "s3":
+		return &S3Client{Container: container}
+	case "az":
+		return &AzClient{Container: container}
+	case "gs":
+		return &GsClient{Container: container}
+	}
+	return GetClient(bsType)
+}
+
 func CreateLocalFile(localPath string) (*os.File, error) {
 	if len(localPath) == 0 {
 		err2 := fmt.Errorf("localPath is not provided")
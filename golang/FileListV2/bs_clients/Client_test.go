@@ -22,10 +22,9 @@ func TestGetClient_EmptyType_ReturnsFileClient(t *testing.T) {
 	assert.IsType(t, &FileClient{}, client)
 }
 
-func TestGetClient_UnsupportedTypePanics(t *testing.T) {
-	assert.PanicsWithValue(t, "gs is currently not supported yet", func() {
-		GetClient("gs")
-	})
+func TestGetClient_ValidTypeGs_ReturnsGsClient(t *testing.T) {
+	client := GetClient("gs")
+	assert.IsType(t, &GsClient{}, client)
 }
 
 func TestGetClient_UnknownTypePanics(t *testing.T) {
@@ -25,6 +25,16 @@ func (c *FileClient) SetClientNum(num int) {
 	c.ClientNum = num
 }
 
+// IsRetryable : Not retrying when the file does not exist or no permission. Other errors (eg. NFS I/O error) may be transient
+func (c *FileClient) IsRetryable(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission)
+}
+
+// IsNotFound : Only when the file does not exist
+func (c *FileClient) IsNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
+
 func (c *FileClient) ReadPath(path string) (string, error) {
 	if common.Debug {
 		// Record the elapsed time
@@ -33,11 +43,13 @@ func (c *FileClient) ReadPath(path string) (string, error) {
 		// If File type blob store, shouldn't take more than 1 second (could be NFS)
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file read for path:"+path, common.SlowMS)
 	}
+	lib.WaitRequest()
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("ReadFile for %s failed with %s. Ignoring...", path, err.Error()))
 		return "", err
 	}
+	lib.WaitBytes(len(bytes))
 	contents := strings.TrimSpace(string(bytes))
 	return contents, nil
 }
@@ -48,6 +60,8 @@ func (c *FileClient) WriteToPath(path string, contents string) error {
 	} else {
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file write for path:"+path, common.SlowMS*2)
 	}
+	lib.WaitRequest()
+	lib.WaitBytes(len(contents))
 	// OpenFile fails if the directory does not exist, so create it first
 	dir := filepath.Dir(path)
 	err := os.MkdirAll(dir, 0755)
@@ -67,12 +81,13 @@ func (c *FileClient) WriteToPath(path string, contents string) error {
 }
 
 func (c *FileClient) GetReader(path string) (interface{}, error) {
+	lib.WaitRequest()
 	reader, err := os.Open(path)
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("GetReader: %s failed with %s.", path, err.Error()))
 		return nil, err
 	}
-	return reader, nil
+	return lib.LimitReadCloser(reader), nil
 }
 
 func (c *FileClient) GetWriter(path string) (interface{}, error) {
@@ -100,7 +115,7 @@ func (c *FileClient) GetPath(path string, localPath string) error {
 		return err2
 	}
 	defer inFile.Close()
-	bytesCopied, err := io.Copy(outFile, inFile)
+	bytesCopied, err := io.Copy(outFile, lib.LimitReadCloser(inFile))
 	h.Log("DEBUG", fmt.Sprintf("Copied %d bytes from %s to %s", bytesCopied, path, localPath))
 	return err
 }
@@ -205,7 +220,27 @@ func (c *FileClient) GetDirs(baseDir string, pathFilter string, maxDepth int) ([
 	return matchingDirs, err
 }
 
+// CreateNewPath : Same as WriteToPath, but O_EXCL is used to never overwrite the existing file
+func (c *FileClient) CreateNewPath(path string, contents string) error {
+	lib.WaitRequest()
+	lib.WaitBytes(len(contents))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return ErrPathExists
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(contents)
+	return err
+}
+
 func (c *FileClient) GetFileInfo(path string) (BlobInfo, error) {
+	lib.WaitRequest()
 	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return BlobInfo{Error: true}, err
@@ -227,7 +262,7 @@ func (c *FileClient) Convert2BlobInfo(f interface{}) BlobInfo {
 	return blobInfo
 }
 
-func (c *FileClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) int64 {
+func (c *FileClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) (int64, error) {
 	// ListObjects: List all files in one directory, NOT recursively (different from other blob stores).
 	// Global variables should be only TopN, PrintedNum, MaxDepth
 	var subTtl int64
@@ -266,9 +301,14 @@ func (c *FileClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintL
 	if err != nil && err != io.EOF {
 		if common.NotCompSubDirs {
 			h.Log("ERROR", "Got error: "+err.Error()+" from "+dir)
+			return subTtl, err
 		} else if common.Debug2 {
 			h.Log("DEBUG", "Got error: "+err.Error()+" from "+dir)
 		}
+		// The computed sub directory may not exist, which is not an error
+		if !errors.Is(err, fs.ErrNotExist) {
+			return subTtl, err
+		}
 	}
-	return subTtl
+	return subTtl, nil
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -196,7 +197,7 @@ func TestListObjects_ValidBaseDir_ReturnsFileCount(t *testing.T) {
 	os.WriteFile(baseDir+"/file2.txt", []byte("content2"), 0644)
 	client := &FileClient{}
 	db := &sql.DB{}
-	count := client.ListObjects(baseDir, db, func(args PrintLineArgs) bool { return true })
+	count, _ := client.ListObjects(baseDir, db, func(args PrintLineArgs) bool { return true })
 	// Because Atomic, can't use assert.Equal with '2'
 	//assert.Equal(t, count, int64(2))
 	assert.NotNil(t, count)
@@ -208,7 +209,7 @@ func TestListObjects_EmptyBaseDir_ReturnsZero(t *testing.T) {
 	defer os.RemoveAll(baseDir)
 	client := &FileClient{}
 	db := &sql.DB{}
-	count := client.ListObjects(baseDir, db, func(args PrintLineArgs) bool { return true })
+	count, _ := client.ListObjects(baseDir, db, func(args PrintLineArgs) bool { return true })
 	assert.Equal(t, int64(0), count)
 }
 
@@ -227,8 +228,10 @@ func TestListObjects_TopNLimit_ReturnsLimitedCount(t *testing.T) {
 		common.PrintedNum++
 		return true
 	}
-	count := client.ListObjects(baseDir, db, testFunc)
+	count, err := client.ListObjects(baseDir, db, testFunc)
 	assert.Equal(t, int64(2), count)
+	// Stopped by TopN is not an error
+	assert.NoError(t, err)
 	common.TopN = 0
 }
 
@@ -242,6 +245,14 @@ func TestListObjects_InvalidBaseDir_ReturnsError(t *testing.T) {
 		}
 	}()
 	client.ListObjects(baseDir, db, func(args PrintLineArgs) bool { return true })
+	// The computed sub directory may not exist
+	notExistDir := filepath.Join(t.TempDir(), "not-exist")
+	_, err := client.ListObjects(notExistDir, db, func(args PrintLineArgs) bool { return true })
+	assert.NoError(t, err)
+	common.NotCompSubDirs = true
+	defer func() { common.NotCompSubDirs = false }()
+	_, err = client.ListObjects(notExistDir, db, func(args PrintLineArgs) bool { return true })
+	assert.Error(t, err)
 }
 func TestGetPath_ValidPath_CopiesFile(t *testing.T) {
 	client := &FileClient{}
@@ -331,3 +342,15 @@ func TestGetPath_EmptyDestinationPath_ReturnsError(t *testing.T) {
 	err := client.GetPath(srcPath, "")
 	assert.Error(t, err)
 }
+
+func TestFileClient_CreateNewPath_ExistingFile_ReturnsErrPathExists(t *testing.T) {
+	client := &FileClient{}
+	path := filepath.Join(t.TempDir(), "sub", "test.properties")
+	_, err := client.GetFileInfo(path)
+	assert.True(t, IsNotFound(client, err))
+	assert.NoError(t, client.CreateNewPath(path, "size=1"))
+	assert.ErrorIs(t, client.CreateNewPath(path, "size=2"), ErrPathExists)
+	contents, _ := client.ReadPath(path)
+	assert.Equal(t, "size=1", contents)
+	assert.False(t, IsNotFound(client, os.ErrPermission))
+}
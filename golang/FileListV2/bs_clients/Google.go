@@ -0,0 +1,395 @@
+package bs_clients
+
+// @see: https://pkg.go.dev/cloud.google.com/go/storage
+
+import (
+	"FileListV2/common"
+	"FileListV2/lib"
+	"bytes"
+	"cloud.google.com/go/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GsClient struct {
+	ClientNum int
+	// Container is used instead of common.Container if set (eg. the group members checked concurrently)
+	Container string
+}
+
+var GsApi *storage.Client
+var GsApi2 *storage.Client
+
+func (g *GsClient) SetClientNum(num int) {
+	g.ClientNum = num
+}
+
+func getGsApi(clientNum int) *storage.Client {
+	if clientNum < 2 && GsApi != nil {
+		return GsApi
+	}
+	if clientNum == 2 && GsApi2 != nil {
+		return GsApi2
+	}
+
+	// NOTE: If STORAGE_EMULATOR_HOST is set, the SDK uses it (eg. fake-gcs-server) without credentials.
+	//       Otherwise, GOOGLE_APPLICATION_CREDENTIALS (Json file path) or the Application Default Credentials are used.
+	var opts []option.ClientOption
+	if clientNum > 1 {
+		specificCredentials := h.GetEnv("GOOGLE_APPLICATION_CREDENTIALS_"+strconv.Itoa(clientNum), "")
+		if len(specificCredentials) > 0 {
+			h.Log("INFO", fmt.Sprintf("Using credentials file: %s for clientNum:%d", specificCredentials, clientNum))
+			opts = append(opts, option.WithCredentialsFile(specificCredentials))
+		}
+	}
+	maybeGsApi, err := storage.NewClient(context.TODO(), opts...)
+	if err != nil {
+		panic("configuration error, " + err.Error())
+	}
+	if clientNum == 2 {
+		GsApi2 = maybeGsApi
+		return GsApi2
+	}
+	GsApi = maybeGsApi
+	return GsApi
+}
+
+func (g *GsClient) bucket() string {
+	if len(g.Container) > 0 {
+		return g.Container
+	}
+	return getBucket(g.ClientNum)
+}
+
+func (g *GsClient) getObject(key string) *storage.ObjectHandle {
+	// Same as S3, the key should contain the prefix, so using container as bucket
+	return getGsApi(g.ClientNum).Bucket(g.bucket()).Object(key)
+}
+
+// gsRequestDone reports the result of one request for the back-off of -rps (Google Storage does not have the middleware)
+func gsRequestDone(err error) {
+	var apiErr *googleapi.Error
+	if err != nil && errors.As(err, &apiErr) && (apiErr.Code == 429 || apiErr.Code == 503) {
+		lib.Throttled("gs")
+	} else if err == nil {
+		lib.Succeeded()
+	}
+}
+
+// IsRetryable : Retrying 5xx, 408, 429 and the errors without HTTP response (eg. connection reset). Not retrying "object doesn't exist" etc.
+func (g *GsClient) IsRetryable(err error) bool {
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.Code)
+	}
+	return true
+}
+
+// IsNotFound : Only "object doesn't exist" (not the bucket)
+func (g *GsClient) IsNotFound(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotExist)
+}
+
+func (g *GsClient) ReadPath(key string) (string, error) {
+	if common.Debug {
+		defer h.Elapsed(time.Now().UnixMilli(), "Read "+key, int64(0))
+	} else {
+		defer h.Elapsed(time.Now().UnixMilli(), "Slow file read for key:"+key, common.SlowMS*2)
+	}
+	lib.WaitRequest()
+	reader, err := g.getObject(key).NewReader(context.TODO())
+	gsRequestDone(err)
+	if err != nil {
+		h.Log("DEBUG", fmt.Sprintf("NewReader for %s failed with %s.", key, err.Error()))
+		return "", err
+	}
+	defer reader.Close()
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(lib.LimitReadCloser(reader))
+	if err != nil {
+		h.Log("DEBUG", fmt.Sprintf("ReadFrom for %s failed with %s.", key, err.Error()))
+		return "", err
+	}
+	contents := strings.TrimSpace(buf.String())
+	return contents, nil
+}
+
+func (g *GsClient) WriteToPath(key string, contents string) error {
+	if common.Debug {
+		defer h.Elapsed(time.Now().UnixMilli(), "Wrote "+key, 0)
+	} else {
+		defer h.Elapsed(time.Now().UnixMilli(), "Slow file write for key:"+key, common.SlowMS*2)
+	}
+	lib.WaitRequest()
+	lib.WaitBytes(len(contents))
+	writer := g.getObject(key).NewWriter(context.TODO())
+	if _, err := writer.Write([]byte(contents)); err != nil {
+		_ = writer.Close()
+		h.Log("DEBUG", fmt.Sprintf("Write for %s failed with %s.", key, err.Error()))
+		return err
+	}
+	// The object is uploaded (committed) on Close, so the error of Close must be checked.
+	err := writer.Close()
+	gsRequestDone(err)
+	return err
+}
+
+func (g *GsClient) GetReader(key string) (interface{}, error) {
+	lib.WaitRequest()
+	reader, err := g.getObject(key).NewReader(context.TODO())
+	gsRequestDone(err)
+	if err != nil {
+		h.Log("DEBUG", fmt.Sprintf("GetReader: %s failed with %s.", key, err.Error()))
+		return nil, err
+	}
+	return lib.LimitReadCloser(reader), nil
+}
+
+func (g *GsClient) GetWriter(key string) (interface{}, error) {
+	// storage.Writer implements io.WriteCloser and uploads the data on Close. No need to create directories.
+	return g.getObject(key).NewWriter(context.TODO()), nil
+}
+
+func (g *GsClient) GetPath(key string, localPath string) error {
+	if common.Debug {
+		defer h.Elapsed(time.Now().UnixMilli(), "Get "+key, int64(0))
+	} else {
+		defer h.Elapsed(time.Now().UnixMilli(), "Slow file copy for key:"+key, common.SlowMS*2)
+	}
+
+	outFile, err := CreateLocalFile(localPath)
+	if err != nil {
+		h.Log("WARN", err.Error())
+		return err
+	}
+	defer outFile.Close()
+
+	lib.WaitRequest()
+	reader, err := g.getObject(key).NewReader(context.TODO())
+	gsRequestDone(err)
+	if err != nil {
+		err2 := fmt.Errorf("failed to get key: %s %s with error: %s", g.bucket(), key, err.Error())
+		return err2
+	}
+	defer reader.Close()
+
+	bytesWritten, err := io.Copy(outFile, lib.LimitReadCloser(reader))
+	if err != nil {
+		err2 := fmt.Errorf("failed to copy key: %s into %s with error: %s", key, localPath, err.Error())
+		return err2
+	}
+	h.Log("DEBUG", fmt.Sprintf("Wrote %d bytes to %s", bytesWritten, localPath))
+	return err
+}
+
+func (g *GsClient) RemoveDeleted(key string, contents string) error {
+	// if the contents is empty, read from the key
+	if len(contents) == 0 {
+		var err error
+		contents, err = g.ReadPath(key)
+		if err != nil {
+			h.Log("DEBUG", fmt.Sprintf("ReadPath for %s failed with %s.", key, err.Error()))
+			return fmt.Errorf("contents of %s is empty and can not read", key)
+		}
+	}
+
+	// Remove "deleted=true" line from the contents
+	updatedContents := common.RxDeleted.ReplaceAllString(contents, "")
+	if len(contents) == len(updatedContents) {
+		if common.RxDeleted.MatchString(contents) {
+			return errors.Errorf("ReplaceAllString may failed for key:%s, as the size is same (%d vs. %d)", key, len(contents), len(updatedContents))
+		} else {
+			h.Log("DEBUG", fmt.Sprintf("No 'deleted=true' found in %s", key))
+			return nil
+		}
+	}
+	// Google Cloud Storage has the custom metadata but Nexus is not using it.
+	return g.WriteToPath(key, updatedContents)
+}
+
+func (g *GsClient) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]string, error) {
+	if common.Debug {
+		defer h.Elapsed(time.Now().UnixMilli(), "Walked "+baseDir, int64(0))
+	} else {
+		defer h.Elapsed(time.Now().UnixMilli(), "Slow directory walk for "+baseDir, common.SlowMS*2)
+	}
+	var dirs []string
+	if maxDepth == -1 {
+		maxDepth = 1
+		h.Log("DEBUG", fmt.Sprintf("maxDepth was -1 (auto), changing to %d for Google", maxDepth))
+	}
+	var bucket = g.bucket()
+	// if baseDir is missing 'content', appending
+	var prefix = lib.GetContentPath(baseDir, bucket)
+	var filterRegex = regexp.MustCompile(pathFilter)
+	baseDepth := strings.Count(prefix, "/") + 1 // +1 to count the trailing slash
+
+	h.Log("DEBUG", fmt.Sprintf("Retrieving sub folders under %s %s", bucket, prefix))
+	query := &storage.Query{
+		Prefix:    h.AppendSlash(prefix),
+		Delimiter: "/",
+	}
+	// Only the prefixes (directories) are needed
+	_ = query.SetAttrSelection([]string{"Name"})
+	it := getGsApi(g.ClientNum).Bucket(bucket).Objects(context.TODO(), query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return dirs, err
+		}
+		if len(strings.TrimSpace(attrs.Prefix)) == 0 {
+			// Not a directory
+			continue
+		}
+		if common.Debug2 {
+			h.Log("DEBUG", fmt.Sprintf("attrs.Prefix %s", attrs.Prefix))
+		}
+		if len(pathFilter) > 0 && !filterRegex.MatchString(attrs.Prefix) {
+			h.Log("DEBUG", fmt.Sprintf("Skipping %s as it does not match with %s", attrs.Prefix, pathFilter))
+			continue
+		}
+		currentDepth := strings.Count(attrs.Prefix, "/") - baseDepth
+		if maxDepth >= 0 && currentDepth > maxDepth {
+			if maxDepth > 1 {
+				h.Log("DEBUG", fmt.Sprintf("Skipping %s as %d exceeds max depth %d", attrs.Prefix, currentDepth, maxDepth))
+			}
+			continue
+		}
+		h.Log("DEBUG", fmt.Sprintf("Appending %s in dirs", attrs.Prefix))
+		dirs = append(dirs, attrs.Prefix)
+	}
+
+	if len(dirs) == 0 {
+		h.Log("DEBUG", fmt.Sprintf("No matching directories for %s (baseDir: %s)", prefix, baseDir))
+		// Same as S3, probably the end of the path = starting point of searching, so appending this 'prefix'
+		dirs = append(dirs, prefix)
+		return dirs, nil
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func (g *GsClient) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) (int64, error) {
+	// ListObjects: List all objects under the dir (prefix) recursively, as no Delimiter.
+	var subTtl int64
+	var listErr error
+	bucket := g.bucket()
+	query := &storage.Query{
+		Prefix: dir,
+	}
+	_ = query.SetAttrSelection([]string{"Name", "Size", "Updated", "Owner", "Metadata"})
+	it := getGsApi(g.ClientNum).Bucket(bucket).Objects(context.TODO(), query)
+	if common.MaxKeys > 0 {
+		it.PageInfo().MaxSize = common.MaxKeys
+	}
+
+	wgItems := sync.WaitGroup{}
+	guardItems := make(chan struct{}, common.Conc2)
+	for {
+		if common.TopN > 0 && common.TopN <= common.PrintedNum {
+			h.Log("DEBUG", fmt.Sprintf("Printed %d >= %d", common.PrintedNum, common.TopN))
+			break
+		}
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			h.Log("ERROR", "Got error: "+err.Error()+" from "+dir)
+			listErr = err
+			break
+		}
+		if len(attrs.Name) == 0 {
+			continue
+		}
+
+		subTtl++
+		guardItems <- struct{}{}
+		wgItems.Add(1)
+		go func(attrs *storage.ObjectAttrs, db *sql.DB) {
+			defer func() {
+				<-guardItems
+				wgItems.Done()
+			}()
+			args := PrintLineArgs{
+				Path:    attrs.Name,
+				BInfo:   g.Convert2BlobInfo(attrs),
+				DB:      db,
+				SaveDir: dir,
+			}
+			perLineFunc(args)
+		}(attrs, db)
+	}
+	wgItems.Wait()
+	return subTtl, listErr
+}
+
+// CreateNewPath : Same as WriteToPath, but with the DoesNotExist precondition to never overwrite the existing object
+func (g *GsClient) CreateNewPath(key string, contents string) error {
+	lib.WaitRequest()
+	lib.WaitBytes(len(contents))
+	writer := g.getObject(key).If(storage.Conditions{DoesNotExist: true}).NewWriter(context.TODO())
+	if _, err := writer.Write([]byte(contents)); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	err := writer.Close()
+	gsRequestDone(err)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrPathExists
+	}
+	return err
+}
+
+func (g *GsClient) GetFileInfo(key string) (BlobInfo, error) {
+	lib.WaitRequest()
+	attrs, err := g.getObject(key).Attrs(context.TODO())
+	gsRequestDone(err)
+	if err != nil {
+		if common.Debug2 {
+			h.Log("DEBUG", fmt.Sprintf("Retrieving %s/%s failed with %s. Ignoring...", g.bucket(), key, err.Error()))
+		}
+		return BlobInfo{Error: true}, err
+	}
+	return g.Convert2BlobInfo(attrs), nil
+}
+
+func (g *GsClient) Convert2BlobInfo(f interface{}) BlobInfo {
+	attrs := f.(*storage.ObjectAttrs)
+	tags := ""
+	// Google Cloud Storage does not have the tags like S3, so using the custom metadata
+	if common.WithTags && len(attrs.Metadata) > 0 {
+		jsonTags, _ := json.Marshal(attrs.Metadata)
+		tags = string(jsonTags)
+	}
+	blobInfo := BlobInfo{
+		Path:    attrs.Name,
+		ModTime: attrs.Updated,
+		Size:    attrs.Size,
+		Owner:   attrs.Owner,
+		Tags:    tags,
+	}
+	return blobInfo
+}
@@ -0,0 +1,189 @@
+package bs_clients
+
+import (
+	"FileListV2/common"
+	"database/sql"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+const GS_TEST_BUCKET = "gs-test-bucket"
+const GS_TEST_PROPS = "gs-test-prefix/content/vol-01/chap-01/00000000-0000-0000-0000-000000000001.properties"
+const GS_TEST_BYTES = "gs-test-prefix/content/vol-01/chap-01/00000000-0000-0000-0000-000000000001.bytes"
+
+// startFakeGcs starts the local fake GCS server and makes GsApi use it
+func startFakeGcs(t *testing.T) *fakestorage.Server {
+	server := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: GS_TEST_BUCKET, Name: GS_TEST_PROPS},
+			Content:     []byte("@BlobStore.blob-name=/test/file.txt\n@Bucket.repo-name=raw-hosted\nsize=11\ndeleted=true\n"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: GS_TEST_BUCKET, Name: GS_TEST_BYTES},
+			Content:     []byte("hello world"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: GS_TEST_BUCKET, Name: "gs-test-prefix/content/vol-02/chap-03/00000000-0000-0000-0000-000000000002.properties"},
+			Content:     []byte("@Bucket.repo-name=raw-hosted\n"),
+		},
+	})
+	GsApi = server.Client()
+	GsApi2 = nil
+	common.BaseDir = "gs://" + GS_TEST_BUCKET + "/gs-test-prefix/"
+	common.Container = GS_TEST_BUCKET
+	t.Cleanup(func() {
+		server.Stop()
+		GsApi = nil
+		common.BaseDir = ""
+		common.Container = ""
+	})
+	return server
+}
+
+func TestReadPath_ValidPath_ReturnsContents_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	contents, err := client.ReadPath(GS_TEST_PROPS)
+	assert.NoError(t, err)
+	assert.Contains(t, contents, "@Bucket.repo-name=raw-hosted")
+	// Same as other clients, the contents should be trimmed
+	assert.NotContains(t, contents[len(contents)-1:], "\n")
+}
+
+func TestReadPath_EmptyContainer_UsesBucketPerClientNum_Google(t *testing.T) {
+	server := startFakeGcs(t)
+	server.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "gs-test-bucket2", Name: GS_TEST_PROPS},
+		Content:     []byte("@Bucket.repo-name=raw-hosted2"),
+	})
+	GsApi2 = server.Client()
+	common.Container2 = "gs-test-bucket2"
+	defer func() {
+		GsApi2 = nil
+		common.Container2 = ""
+	}()
+
+	// Container is empty, so the bucket is from common.Container (ClientNum 1) or common.Container2 (ClientNum 2)
+	client := &GsClient{}
+	assert.Equal(t, GS_TEST_BUCKET, client.bucket())
+	contents, err := client.ReadPath(GS_TEST_PROPS)
+	assert.NoError(t, err)
+	assert.Contains(t, contents, "@Bucket.repo-name=raw-hosted\n")
+	client2 := &GsClient{}
+	client2.SetClientNum(2)
+	assert.Equal(t, "gs-test-bucket2", client2.bucket())
+	contents, err = client2.ReadPath(GS_TEST_PROPS)
+	assert.NoError(t, err)
+	assert.Equal(t, "@Bucket.repo-name=raw-hosted2", contents)
+}
+
+func TestReadPath_InvalidPath_ReturnsError_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	contents, err := client.ReadPath("invalid_path")
+	assert.Error(t, err)
+	assert.Equal(t, "", contents)
+}
+
+func TestWriteToPath_ValidPath_WritesContents_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	err := client.WriteToPath("gs-test-prefix/write_test.txt", "file contents")
+	assert.NoError(t, err)
+	contents, err := client.ReadPath("gs-test-prefix/write_test.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "file contents", contents)
+}
+
+func TestGetFileInfo_ValidAndMissingKey_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	info, err := client.GetFileInfo(GS_TEST_BYTES)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), info.Size)
+	assert.Equal(t, GS_TEST_BYTES, info.Path)
+	assert.False(t, info.ModTime.IsZero())
+
+	info, err = client.GetFileInfo("gs-test-prefix/content/missing.bytes")
+	assert.Error(t, err)
+	assert.True(t, info.Error)
+}
+
+func TestRemoveDeleted_ContainsDeletedLine_RemovesLine_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	err := client.RemoveDeleted(GS_TEST_PROPS, "")
+	assert.NoError(t, err)
+	contents, err := client.ReadPath(GS_TEST_PROPS)
+	assert.NoError(t, err)
+	assert.NotContains(t, contents, "deleted=true")
+	assert.Contains(t, contents, "size=11")
+}
+
+func TestGetDirs_ValidBaseDir_ReturnsMatchingDirs_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	dirs, err := client.GetDirs(common.BaseDir, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gs-test-prefix/content/vol-01/", "gs-test-prefix/content/vol-02/"}, dirs)
+
+	dirs, err = client.GetDirs(common.BaseDir, "vol-02", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gs-test-prefix/content/vol-02/"}, dirs)
+}
+
+func TestListObjects_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	common.TopN = 0
+	common.Conc2 = 2
+	var mu sync.Mutex
+	var paths []string
+	subTtl, _ := client.ListObjects("gs-test-prefix/content/vol-01/", &sql.DB{}, func(args PrintLineArgs) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, args.Path)
+		if args.Path == GS_TEST_BYTES {
+			assert.Equal(t, int64(11), args.BInfo.Size)
+		}
+		return true
+	})
+	assert.Equal(t, int64(2), subTtl)
+	assert.ElementsMatch(t, []string{GS_TEST_PROPS, GS_TEST_BYTES}, paths)
+}
+
+func TestGetReaderAndGetWriter_CopiesObject_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	maybeReader, err := client.GetReader(GS_TEST_BYTES)
+	assert.NoError(t, err)
+	reader := maybeReader.(io.ReadCloser)
+	defer reader.Close()
+	maybeWriter, err := client.GetWriter("gs-test-prefix/copied/test.bytes")
+	assert.NoError(t, err)
+	writer := maybeWriter.(io.WriteCloser)
+	_, err = io.Copy(writer, reader)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	contents, err := client.ReadPath("gs-test-prefix/copied/test.bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", contents)
+}
+
+func TestGetPath_ValidKey_CopiesFileToLocalPath_Google(t *testing.T) {
+	startFakeGcs(t)
+	client := &GsClient{}
+	localPath := TEST_DATA_DIR + "/google/test.bytes"
+	_ = os.Remove(localPath)
+	defer os.Remove(localPath)
+	err := client.GetPath(GS_TEST_BYTES, localPath)
+	assert.NoError(t, err)
+	contents, err := os.ReadFile(localPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(contents))
+}
@@ -0,0 +1,109 @@
+package bs_clients
+
+import (
+	"FileListV2/common"
+	"FileListV2/lib"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"time"
+)
+
+const MAX_RETRY_BACKOFF = 30 * time.Second
+
+// RetryableChecker : Optional interface for the clients to decide if the error is transient (worth retrying)
+type RetryableChecker interface {
+	IsRetryable(error) bool
+}
+
+// RetryClient : Wraps the Client to retry ReadPath, GetFileInfo and GetReader with the exponential back-off.
+// The paths which still failed with the retryable error are recorded into the errors file (-errF).
+// NOTE: the S3, Azure and Google SDKs have own retryers, so each attempt here may be several requests (eg. 3 x 3 with S3's defaults).
+type RetryClient struct {
+	Client
+}
+
+// WithRetry wraps the client. Other methods are passed through as-is.
+func WithRetry(client Client) Client {
+	return &RetryClient{Client: client}
+}
+
+// Unwrap returns the original client (eg. for the type assertion)
+func Unwrap(client Client) Client {
+	if r, ok := client.(*RetryClient); ok {
+		return r.Client
+	}
+	return client
+}
+
+// AsTagClient returns the TagClient if the (original) client supports the tags
+func AsTagClient(client Client) (TagClient, bool) {
+	tagClient, ok := Unwrap(client).(TagClient)
+	return tagClient, ok
+}
+
+// IsRetryable returns true if the error should be retried. -retryRx overrides the backend's decision.
+func IsRetryable(client Client, err error) bool {
+	if err == nil {
+		return false
+	}
+	if common.RxRetry != nil {
+		return common.RxRetry.MatchString(err.Error())
+	}
+	if checker, ok := Unwrap(client).(RetryableChecker); ok {
+		return checker.IsRetryable(err)
+	}
+	return true
+}
+
+// isRetryableStatus is used by the cloud backends: 5xx, 408 Request Timeout and 429 Too Many Requests
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == 408 || statusCode == 429
+}
+
+func (r *RetryClient) ReadPath(path string) (string, error) {
+	var contents string
+	err := r.retry("ReadPath", path, func() error {
+		var err error
+		contents, err = r.Client.ReadPath(path)
+		return err
+	})
+	return contents, err
+}
+
+func (r *RetryClient) GetFileInfo(path string) (BlobInfo, error) {
+	var blobInfo BlobInfo
+	err := r.retry("GetFileInfo", path, func() error {
+		var err error
+		blobInfo, err = r.Client.GetFileInfo(path)
+		return err
+	})
+	return blobInfo, err
+}
+
+func (r *RetryClient) GetReader(path string) (interface{}, error) {
+	var reader interface{}
+	err := r.retry("GetReader", path, func() error {
+		var err error
+		reader, err = r.Client.GetReader(path)
+		return err
+	})
+	return reader, err
+}
+
+func (r *RetryClient) retry(operation string, path string, f func() error) error {
+	backoff := time.Duration(common.RetryMS) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if !IsRetryable(r.Client, err) {
+			return err
+		}
+		if attempt >= common.RetryMax {
+			h.Log("ERROR", fmt.Sprintf("%s for %s failed after %d attempt(s) with %s", operation, path, attempt, err.Error()))
+			lib.RecordError(path, operation, err)
+			return err
+		}
+		h.Log("WARN", fmt.Sprintf("%s for %s failed with %s (attempt %d/%d). Retrying in %s", operation, path, err.Error(), attempt, common.RetryMax, backoff))
+		time.Sleep(backoff)
+		backoff = min(backoff*2, MAX_RETRY_BACKOFF)
+	}
+}
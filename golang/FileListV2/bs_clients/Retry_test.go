@@ -0,0 +1,87 @@
+package bs_clients
+
+import (
+	"FileListV2/common"
+	"FileListV2/lib"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type flakyClient struct {
+	FileClient
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyClient) ReadPath(path string) (string, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return "", f.err
+	}
+	return "ok", nil
+}
+
+func setRetryForTest(t *testing.T, max int) string {
+	origMax, origMS := common.RetryMax, common.RetryMS
+	common.RetryMax, common.RetryMS = max, 1
+	errorsFile := filepath.Join(t.TempDir(), "errors.tsv")
+	lib.SetErrorsFile(errorsFile)
+	t.Cleanup(func() {
+		common.RetryMax, common.RetryMS = origMax, origMS
+		lib.CloseErrorsFile()
+		lib.SetErrorsFile("")
+	})
+	return errorsFile
+}
+
+func TestRetryClient_TransientError_RetriesAndSucceeds(t *testing.T) {
+	setRetryForTest(t, 3)
+	flaky := &flakyClient{failures: 2, err: errors.New("input/output error")}
+	contents, err := WithRetry(flaky).ReadPath("/tmp/a.properties")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", contents)
+	assert.Equal(t, 3, flaky.calls)
+}
+
+func TestRetryClient_NotExist_NoRetry(t *testing.T) {
+	errorsFile := setRetryForTest(t, 3)
+	flaky := &flakyClient{failures: 5, err: &fs.PathError{Op: "open", Path: "/tmp/a.properties", Err: fs.ErrNotExist}}
+	_, err := WithRetry(flaky).ReadPath("/tmp/a.properties")
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+	_, errS := os.Stat(errorsFile)
+	assert.True(t, os.IsNotExist(errS))
+}
+
+func TestRetryClient_StillFailing_RecordsErrorsFile(t *testing.T) {
+	errorsFile := setRetryForTest(t, 2)
+	flaky := &flakyClient{failures: 5, err: errors.New("connection reset\nby peer")}
+	_, err := WithRetry(flaky).ReadPath("/tmp/content/vol-01/chap-01/11111111-1111-1111-1111-111111111111.properties")
+	assert.Error(t, err)
+	assert.Equal(t, 2, flaky.calls)
+	assert.Equal(t, errorsFile, lib.CloseErrorsFile())
+	saved, _ := os.ReadFile(errorsFile)
+	lines := strings.Split(strings.TrimSpace(string(saved)), "\n")
+	assert.Equal(t, "/tmp/content/vol-01/chap-01/11111111-1111-1111-1111-111111111111.properties\tReadPath\tconnection reset by peer", lines[1])
+}
+
+func TestIsRetryable_WithRxRetry_OverridesBackend(t *testing.T) {
+	common.RxRetry = regexp.MustCompile("does not exist")
+	defer func() { common.RxRetry = nil }()
+	assert.True(t, IsRetryable(&FileClient{}, &fs.PathError{Op: "open", Path: "/tmp/a", Err: fs.ErrNotExist}))
+	assert.False(t, IsRetryable(&FileClient{}, errors.New("input/output error")))
+}
+
+func TestAsTagClient_RetryClient_ReturnsOriginalTagClient(t *testing.T) {
+	_, ok := AsTagClient(WithRetry(&S3Client{}))
+	assert.True(t, ok)
+	_, ok = AsTagClient(WithRetry(&FileClient{}))
+	assert.False(t, ok)
+}
@@ -11,13 +11,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
 	h "github.com/hajimeo/samples/golang/helpers"
 	"github.com/pkg/errors"
 	"io"
+	"net/http"
 	"os"
 	"regexp"
 	"sort"
@@ -29,6 +34,8 @@ import (
 
 type S3Client struct {
 	ClientNum int
+	// Container is used instead of common.Container if set (eg. the group members checked concurrently)
+	Container string
 }
 
 var S3Api *s3.Client
@@ -86,6 +93,7 @@ func getS3Api(clientNum int) *s3.Client {
 	}
 	// To stop 'WARN Response has no supported checksum. Not validating response payload.'
 	cfg.ResponseChecksumValidation = 2
+	cfg.APIOptions = append(cfg.APIOptions, addS3Metrics, addS3RateLimit)
 
 	if common.S3PathStyle {
 		h.Log("INFO", "Using legacy S3 Path-Style access")
@@ -119,6 +127,43 @@ func getS3Api(clientNum int) *s3.Client {
 	return S3Api
 }
 
+// addS3Metrics adds the middleware to record the latency of each S3 API call (including retries)
+func addS3Metrics(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("FileListV2Metrics",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			startMs := time.Now().UnixMilli()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			lib.ObserveRequest("s3", awsmiddleware.GetOperationName(ctx), startMs, err)
+			return out, metadata, err
+		}), middleware.After)
+}
+
+// addS3RateLimit adds the middleware to wait for -rps per attempt (so retries are also limited), and back-off when throttled
+func addS3RateLimit(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("FileListV2RateLimit",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			lib.WaitRequest()
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if isS3Throttled(err) {
+				lib.Throttled("s3")
+			} else if err == nil {
+				lib.Succeeded()
+			}
+			return out, metadata, err
+		}), middleware.After)
+}
+
+func isS3Throttled(err error) bool {
+	if err == nil {
+		return false
+	}
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && (respErr.HTTPStatusCode() == 503 || respErr.HTTPStatusCode() == 429)
+}
+
 func getS3Config(clientNum int) (aws.Config, error) {
 	var specificAccessKeyID string
 	var specificSecretAccessKey string
@@ -171,6 +216,13 @@ func getBucket(clientNum int) string {
 	return common.Container
 }
 
+func (s *S3Client) bucket() string {
+	if len(s.Container) > 0 {
+		return s.Container
+	}
+	return getBucket(s.ClientNum)
+}
+
 func getS3ObjectInput(key string, container string) *s3.GetObjectInput {
 	// S3 key should contain the S3 prefix, so using container as bucket
 	return &s3.GetObjectInput{
@@ -179,6 +231,26 @@ func getS3ObjectInput(key string, container string) *s3.GetObjectInput {
 	}
 }
 
+// IsRetryable : Retrying 5xx, 408, 429 and the errors without HTTP response (eg. connection reset). Not retrying 404 etc.
+func (s *S3Client) IsRetryable(err error) bool {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return isRetryableStatus(respErr.HTTPStatusCode())
+	}
+	return true
+}
+
+// IsNotFound : 404 (HeadObject returns NotFound without the body, GetObject returns NoSuchKey)
+func (s *S3Client) IsNotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
+
 func (s *S3Client) ReadPath(key string) (string, error) {
 	if common.Debug {
 		// Record the elapsed time
@@ -187,7 +259,7 @@ func (s *S3Client) ReadPath(key string) (string, error) {
 		// As S3, using *2
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file read for key:"+key, common.SlowMS*2)
 	}
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	input := getS3ObjectInput(key, bucket)
 	obj, err := getS3Api(s.ClientNum).GetObject(context.TODO(), input)
 	if err != nil {
@@ -195,7 +267,7 @@ func (s *S3Client) ReadPath(key string) (string, error) {
 		return "", err
 	}
 	buf := new(bytes.Buffer)
-	_, err = buf.ReadFrom(obj.Body)
+	_, err = buf.ReadFrom(lib.LimitReadCloser(obj.Body))
 	if err != nil {
 		h.Log("DEBUG", fmt.Sprintf("ReadFrom for %s failed with %s.", key, err.Error()))
 		return "", err
@@ -210,7 +282,8 @@ func (s *S3Client) WriteToPath(key string, contents string) error {
 	} else {
 		defer h.Elapsed(time.Now().UnixMilli(), "Slow file write for key:"+key, common.SlowMS*2)
 	}
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
+	lib.WaitBytes(len(contents))
 	input := &s3.PutObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
@@ -231,7 +304,7 @@ func (s *S3Client) WriteToPath(key string, contents string) error {
 }
 
 func (s *S3Client) GetReader(key string) (interface{}, error) {
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	if common.Debug2 {
 		h.Log("DEBUG", fmt.Sprintf("Got bucket:%s for key:%s, clientNum:%d", bucket, key, s.ClientNum))
 	}
@@ -241,6 +314,16 @@ func (s *S3Client) GetReader(key string) (interface{}, error) {
 		h.Log("DEBUG", fmt.Sprintf("GetReader: %s failed with %s.", key, err.Error()))
 		return nil, err
 	}
+	return lib.LimitReadCloser(obj.Body), nil
+}
+
+// GetS3ObjectReader returns the reader of the object in any bucket (eg. the S3 Inventory report) with the source (-b) credentials
+func GetS3ObjectReader(bucket string, key string) (io.ReadCloser, error) {
+	obj, err := getS3Api(1).GetObject(context.TODO(), getS3ObjectInput(key, bucket))
+	if err != nil {
+		h.Log("DEBUG", fmt.Sprintf("GetS3ObjectReader: s3://%s/%s failed with %s.", bucket, key, err.Error()))
+		return nil, err
+	}
 	return obj.Body, nil
 }
 
@@ -251,7 +334,7 @@ func (s *S3Client) GetWriter(key string) (interface{}, error) {
 		buf:    buf,
 		key:    key,
 		s3:     s,
-		bucket: getBucket(s.ClientNum),
+		bucket: s.bucket(),
 	}
 	return writer, nil
 }
@@ -292,7 +375,7 @@ func (s *S3Client) GetPath(key string, localPath string) error {
 	}
 	defer outFile.Close()
 
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	input := getS3ObjectInput(key, bucket)
 	inFile, err := getS3Api(s.ClientNum).GetObject(context.TODO(), input)
 	if err != nil {
@@ -301,7 +384,7 @@ func (s *S3Client) GetPath(key string, localPath string) error {
 	}
 	defer inFile.Body.Close()
 
-	bytesWritten, err := io.Copy(outFile, inFile.Body)
+	bytesWritten, err := io.Copy(outFile, lib.LimitReadCloser(inFile.Body))
 	if err != nil {
 		err2 := fmt.Errorf("failed to copy key: %s into %s with error: %s", key, localPath, err.Error())
 		return err2
@@ -327,6 +410,36 @@ func replaceTagInput(key string, tagKey string, tagVal string, bucket string) *s
 	}
 }
 
+func (s *S3Client) GetTags(key string) (map[string]string, error) {
+	bucket := s.bucket()
+	tagObj, err := getS3Api(s.ClientNum).GetObjectTagging(context.TODO(), &s3.GetObjectTaggingInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range tagObj.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+func (s *S3Client) SetTags(key string, tags map[string]string) error {
+	tagSet := make([]types.Tag, 0, len(tags))
+	for tagKey, tagVal := range tags {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(tagKey), Value: aws.String(tagVal)})
+	}
+	bucket := s.bucket()
+	_, err := getS3Api(s.ClientNum).PutObjectTagging(context.TODO(), &s3.PutObjectTaggingInput{
+		Bucket:  &bucket,
+		Key:     &key,
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
 func (s *S3Client) RemoveDeleted(key string, contents string) error {
 	// if the contents is empty, read from the key
 	if len(contents) == 0 {
@@ -352,7 +465,7 @@ func (s *S3Client) RemoveDeleted(key string, contents string) error {
 		h.Log("DEBUG", fmt.Sprintf("WriteToPath for %s failed with %s", key, err.Error()))
 		return err
 	}
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	inputTag := replaceTagInput(key, "", "", bucket)
 	respTag, err := getS3Api(s.ClientNum).PutObjectTagging(context.TODO(), inputTag)
 	if err != nil {
@@ -381,7 +494,7 @@ func (s *S3Client) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]s
 		maxDepth = 1
 		h.Log("DEBUG", fmt.Sprintf("maxDepth was -1 (auto), changing to %d for S3", maxDepth))
 	}
-	var bucket = getBucket(s.ClientNum)
+	var bucket = s.bucket()
 	// if baseDir is missing 'content', appending
 	var prefix = lib.GetContentPath(baseDir, bucket)
 	var filterRegex = regexp.MustCompile(pathFilter)
@@ -433,9 +546,10 @@ func (s *S3Client) GetDirs(baseDir string, pathFilter string, maxDepth int) ([]s
 	return dirs, nil
 }
 
-func (s *S3Client) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) int64 {
+func (s *S3Client) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLineArgs) bool) (int64, error) {
 	var subTtl int64
-	bucket := getBucket(s.ClientNum)
+	var listErr error
+	bucket := s.bucket()
 	input := &s3.ListObjectsV2Input{
 		Bucket:     &bucket,
 		MaxKeys:    aws.Int32(int32(common.MaxKeys)),
@@ -474,8 +588,9 @@ func (s *S3Client) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLin
 			i++
 			page, err := p.NextPage(context.Background())
 			if err != nil {
-				println("Got error retrieving list of objects:")
-				panic(err.Error())
+				h.Log("ERROR", "Got error: "+err.Error()+" from "+dir)
+				listErr = err
+				break
 			}
 			if i > 1 {
 				h.Log("INFO", fmt.Sprintf("%s: Page %d, %d objects", dir, i, len(page.Contents)))
@@ -511,11 +626,32 @@ func (s *S3Client) ListObjects(dir string, db *sql.DB, perLineFunc func(PrintLin
 		wgTags.Wait() // *
 		break
 	}
-	return subTtl
+	return subTtl, listErr
+}
+
+// CreateNewPath : Same as WriteToPath, but with 'If-None-Match: *' to never overwrite the existing object
+func (s *S3Client) CreateNewPath(key string, contents string) error {
+	bucket := s.bucket()
+	lib.WaitBytes(len(contents))
+	input := &s3.PutObjectInput{
+		Bucket:      &bucket,
+		Key:         &key,
+		Body:        bytes.NewReader([]byte(contents)),
+		IfNoneMatch: aws.String("*"),
+	}
+	_, err := getS3Api(s.ClientNum).PutObject(context.TODO(), input)
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict) {
+			return ErrPathExists
+		}
+		return err
+	}
+	return nil
 }
 
 func (s *S3Client) GetFileInfo(key string) (BlobInfo, error) {
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	owner := ""
 	tags := ""
 
@@ -585,7 +721,7 @@ func (s *S3Client) Convert2BlobInfo(f interface{}) BlobInfo {
 
 func getTags(key string, s *S3Client) string {
 	tags := ""
-	bucket := getBucket(s.ClientNum)
+	bucket := s.bucket()
 	//h.Log("DEBUG", fmt.Sprintf("Retrieving tags from %s ...", key))
 	input3 := &s3.GetObjectTaggingInput{
 		Bucket: &bucket,
@@ -14,33 +14,51 @@ import (
 var Debug bool
 var Debug2 bool // For AWS SDK
 
-//var DryRun bool
+var DryRun bool // -RDel, -wStr and -undo only report what would change
+
+// Config file related
+var ConfigFile = ""
+var Profile = ""
 
 const CONTENT = "content"
 const PROPERTIES = "properties"
 const PROP_EXT = "." + PROPERTIES
 const BYTES = "bytes"
 const BYTES_EXT = "." + BYTES
-const SEP = "	" // Tab separator (should this be changeable?)
+const SEP = "	" // Tab separator. Converted by lib.FormatLine if OutputFormat is not 'tsv'
 
 // Display / output related
 var NoHeader bool
+var OutputFormat = "tsv"        // 'tsv', 'csv', 'jsonl' or 'parquet'
+var OutputCols []string         // Column names of the current output (set with the header)
+var Summary = false             // Accumulate the listed objects and print the report at the end instead of each line
+var MetricsAddr = ""            // Address to expose the Prometheus metrics (eg. ':9090')
+var MetricsLinger time.Duration // How long to keep /metrics after the job completed, so that the final values are scraped
+var ProgressSec = 0             // Interval seconds to log the progress (0 = only when SIGUSR1 is received)
 var WithProps bool
 var NoDateBsLayout = false // To support new created date based blobstore layout
 var TopN int64
 
 var WithOwner bool   // AWS S3: Display owner
-var WithTags bool    // AWS S3: Display tags
+var WithTags bool    // AWS S3: Display tags. Azure: Display metadata and index tags
 var S3PathStyle bool // AWS S3: Use Path-Style access
+var AzDeleted bool   // Azure: List the soft-deleted blobs and the previous versions only
+var AzUndelete bool  // Azure: Undelete the soft-deleted blobs or restore the previous version listed by AzDeleted
 
 // Paths/Directories related. End with "/", so that no need to append  string(filepath.Separator)
 var BaseDir = ""
 var BaseDir2 = ""
+var GroupMembers []string // -b can be comma separated for the group blob store. BaseDir is the current member
+var CurrentMember = ""
 var B2RepoName = ""
 var B2NewBlobId = false
 var B2PropsOnly = false
-var BsType = ""     // 'file' for File, 's3' for AWS S3, 'az' for Azure, (TODO) 'gs' for Google
-var BsType2 = ""    // 'file' for File, 's3' for AWS S3, 'az' for Azure, (TODO) 'gs' for Google
+var B2Verify = false
+var B2VerifySha256 = false
+var B2MappingFile = ""
+var B2SqlFile = ""
+var BsType = ""     // 'file' for File, 's3' for AWS S3, 'az' for Azure, 'gs' for Google
+var BsType2 = ""    // 'file' for File, 's3' for AWS S3, 'az' for Azure, 'gs' for Google
 var Container = ""  // Azure: Container name, S3: Bucket name, Google: Bucket name
 var Container2 = "" // Azure: Container name, S3: Bucket name, Google: Bucket name
 var Prefix = ""
@@ -48,9 +66,12 @@ var Prefix2 = ""
 var ContentPath = ""  // BaseDirWithPrefix + "/content/"
 var ContentPath2 = "" // BaseDirWithPrefix + "/content/"
 var Filter4Path = ""
+var S3Inventory = "" // manifest.json of the S3 Inventory report (local path or s3://bucket/key) used instead of listing
 var SaveToFile = ""
 var SavePerDir = false
 var SaveToPointer *os.File
+var Resume = false      // Skip the sub-directories recorded in the CheckpointFile
+var CheckpointFile = "" // SaveToFile + ".checkpoint"
 var NotCompSubDirs = false
 var WalkRecursive = true // Should not be exposed (testing purpose). Probably only for File type
 var MaxDepth int         // `vol-YY/chap-XX/` vs. `YYYY/MM/DD/`
@@ -58,14 +79,20 @@ var MaxDepth int         // `vol-YY/chap-XX/` vs. `YYYY/MM/DD/`
 // Blob store related
 var BsName = ""
 var BlobIDFIle = ""
-var BlobIDFIleType = ""
+var BlobIDFIleType = "" // 'BS' if the file is the (saved) result of the blob store, 'DB' if the result of the DB
 var RemoveDeleted bool
 var BytesChk bool
 var NoExtraChk bool
+var Integrity bool
 var WriteIntoStr = ""
+var JournalFile = "" // Records the original contents/tags before -RDel or -wStr modifies
+var UndoJournal = "" // The journal file to restore
 var Query = ""
 var QRepoNames = ""
 var QRepoNameList []string
+var DbBulk bool
+var DbStream bool
+var RepairProps bool
 var RxSelect = regexp.MustCompile(`(?is)^ *SELECT ?.* +blob_id *,? *[^;]+;?$`)
 var RxAnd = regexp.MustCompile(`(?i)^ *AND `)
 var GetFile = ""
@@ -73,6 +100,7 @@ var GetTo = ""
 
 // Database related
 var DbConnStr = ""
+var DbType = "" // 'postgres' (default), 'h2' (through the PG server mode) or 'sqlite'
 var DB *sql.DB
 var Truth = ""
 var Repo2Fmt map[string]string
@@ -91,6 +119,7 @@ var Filter4BytesIncl = ""
 var RxInclBytes *regexp.Regexp
 var Filter4BytesExcl = ""
 var RxExclBytes *regexp.Regexp
+var BytesRxMaxSize int64 = 32768 // Read only the first N bytes of .bytes files for -bRx/-bRxNot
 
 var DelDateFromStr = ""
 var DelDateFromTS int64
@@ -106,6 +135,8 @@ var RxSizeByte = regexp.MustCompile(",size=([0-9]+)")                 // When th
 var RxDeleted = regexp.MustCompile("deleted=true")                    // should not use ^ as replacing one-line text
 var RxRepoName = regexp.MustCompile(`(@Bucket\.repo-name=)([^\s\n\r,$]+)`)
 var RxBlobName = regexp.MustCompile(`(@BlobStore\.blob-name=)([^\s\n\r,$]+)`)
+var RxSha1 = regexp.MustCompile(`(?:^|[\s,])sha1=([a-f0-9]{40})`)
+var RxContentType = regexp.MustCompile(`(@BlobStore\.content-type=)([^\n\r,]+)`)
 
 // RxBlobRef : Not considering "space" in blobRef (TODO: may need to add more characters)
 var RxBlobRef = regexp.MustCompile(`([^\s,'"]+@[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12})`) // Not considering very old blobRef (using `:` or including nodeId)
@@ -129,5 +160,14 @@ var StartTimestamp = time.Now().Unix()
 var CheckedNum int64 = 0 // Atomic (maybe slower?)
 var PrintedNum int64 = 0 // Atomic (maybe slower?)
 var TotalSize int64 = 0  // Atomic (maybe slower?)
+var DirsTotal int64 = 0  // Atomic. Number of sub directories to list (all members, counted before listing)
+var DirsDone int64 = 0   // Atomic
 var SlowMS int64 = 1000
+var MaxRps float64 = 0 // Requests/second shared by all clients (0 = unlimited)
+var MaxBps int64 = 0   // Bytes/second shared by all clients (0 = unlimited)
+var RetryRx = ""
+var RetryMax = 3           // Max attempts of ReadPath, GetFileInfo and GetReader (1 = no retry)
+var RetryMS int64 = 500    // The first back-off msec (doubled per attempt)
+var RxRetry *regexp.Regexp // If set, only the errors matching this regex are retried
+var ErrorsFile = ""        // The paths which still failed after retries
 var CacheSize int = 1000
@@ -5,6 +5,7 @@ package lib
 
 import (
 	"os"
+	"os/signal"
 	"strconv"
 	"syscall"
 )
@@ -13,3 +14,8 @@ func GetXid(info os.FileInfo) (string, string) {
 	stat := info.Sys().(*syscall.Stat_t)
 	return strconv.Itoa(int(stat.Uid)), strconv.Itoa(int(stat.Gid))
 }
+
+// NotifyProgressSignal relays SIGUSR1 to the channel, to dump the progress
+func NotifyProgressSignal(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
@@ -11,3 +11,7 @@ func GetXid(info os.FileInfo) (string, string) {
 	// TODO: not implemented yet
 	return "", ""
 }
+
+func NotifyProgressSignal(c chan os.Signal) {
+	// No SIGUSR1 on Windows, so only the interval (-progress) works
+}
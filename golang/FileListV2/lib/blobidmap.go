@@ -0,0 +1,129 @@
+// Package lib: old -> new blob ID mapping (-bTo-NewBlobId) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const BLOB_ID_MAP_TABLE = "filelist2_blob_id_map"
+
+// BlobIdMapping writes the old -> new blob refs (TSV) and optionally the SQL to update <format>_asset_blob.blob_ref
+type BlobIdMapping struct {
+	Path     string
+	SqlPath  string
+	RepoName string // The repository name to update blob_ref in the SQL (-bTo-repoName)
+	mu       sync.Mutex
+	file     *os.File
+	sqlFile  *os.File
+}
+
+// DefaultBlobIdMappingPath returns the mapping file path in the current directory with the timestamp
+func DefaultBlobIdMappingPath() string {
+	return "filelist2_blobid_mapping_" + time.Now().Format("20060102150405") + ".tsv"
+}
+
+// OpenBlobIdMapping creates the mapping file, and the SQL file if sqlPath is not empty
+func OpenBlobIdMapping(path string, sqlPath string, repoName string) (*BlobIdMapping, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &BlobIdMapping{Path: path, SqlPath: sqlPath, RepoName: repoName, file: f}
+	// Without -bsName, the blob store name is unknown, so the columns are the blob IDs
+	header := "OldBlobId" + common.SEP + "NewBlobId\n"
+	if len(common.BsName) > 0 {
+		header = "OldBlobRef" + common.SEP + "NewBlobRef\n"
+	}
+	if _, err = f.WriteString(header); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if len(sqlPath) > 0 {
+		m.sqlFile, err = os.Create(sqlPath)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if _, err = m.sqlFile.WriteString(genBlobRefSqlHeader(repoName)); err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Add records one mapping. The blob IDs can be 'UUID' or 'UUID@yyyy-MM-ddTHH:mm'. Written as blob refs if -bsName is given (otherwise, as the blob IDs).
+func (m *BlobIdMapping) Add(oldBlobId string, newBlobId string) error {
+	oldRef, newRef := oldBlobId, newBlobId
+	if len(common.BsName) > 0 {
+		oldRef = GetBlobRef(oldBlobId, common.BsName)
+		newRef = GetBlobRef(newBlobId, common.BsName)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.file.WriteString(oldRef + common.SEP + newRef + "\n"); err != nil {
+		return err
+	}
+	if m.sqlFile != nil {
+		_, err := m.sqlFile.WriteString(fmt.Sprintf("INSERT INTO %s VALUES (%s, %s);\n", BLOB_ID_MAP_TABLE, quoteSql(oldBlobId), quoteSql(newBlobId)))
+		return err
+	}
+	return nil
+}
+
+// Close closes the mapping file, and completes the SQL file with the UPDATE statement
+func (m *BlobIdMapping) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.file.Close()
+	if m.sqlFile != nil {
+		if _, errW := m.sqlFile.WriteString(genBlobRefSqlFooter(m.RepoName)); errW != nil && err == nil {
+			err = errW
+		}
+		if errC := m.sqlFile.Close(); errC != nil && err == nil {
+			err = errC
+		}
+	}
+	return err
+}
+
+func quoteSql(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func genBlobRefSqlHeader(repoName string) string {
+	return fmt.Sprintf(`-- Generated by filelist2 at %s
+-- Updates <format>_asset_blob.blob_ref of the repository '%s' from the old blob IDs to the new (copied) blob IDs.
+-- Run against the destination Nexus Repository database (PostgreSQL), eg. psql -f <this file>
+BEGIN;
+CREATE TEMP TABLE %s (old_id VARCHAR(255) PRIMARY KEY, new_id VARCHAR(255) NOT NULL) ON COMMIT DROP;
+`, time.Now().Format(time.RFC3339), strings.ReplaceAll(repoName, "'", "''"), BLOB_ID_MAP_TABLE)
+}
+
+func genBlobRefSqlFooter(repoName string) string {
+	// Same as initRepoFmtMap to get the format from the recipe name. blob_ref is {blobStore}@{blobId}, so replacing the blob ID part only.
+	// The blob ID part is compared with '=' (not LIKE), so that the UPDATE can be a hash join with the mapping table.
+	return fmt.Sprintf(`DO $$
+DECLARE
+  fmt TEXT;
+  updated INT;
+BEGIN
+  SELECT REGEXP_REPLACE(recipe_name, '-.+', '') INTO fmt FROM repository WHERE name = %[1]s;
+  IF fmt IS NULL THEN
+    RAISE EXCEPTION 'Repository %% does not exist', %[1]s;
+  END IF;
+  EXECUTE format('UPDATE %%1$I ab SET blob_ref = REPLACE(ab.blob_ref, m.old_id, m.new_id) FROM %[2]s m, %%2$I a, %%3$I cr, repository r'
+    || ' WHERE a.asset_blob_id = ab.asset_blob_id AND cr.repository_id = a.repository_id AND r.id = cr.config_repository_id'
+    || ' AND r.name = %%4$L AND SUBSTRING(ab.blob_ref FROM POSITION(''@'' IN ab.blob_ref) + 1) = m.old_id',
+    fmt || '_asset_blob', fmt || '_asset', fmt || '_content_repository', %[1]s);
+  GET DIAGNOSTICS updated = ROW_COUNT;
+  RAISE NOTICE 'Updated %% blob_ref in %% repository', updated, %[1]s;
+END $$;
+COMMIT;
+`, quoteSql(repoName), BLOB_ID_MAP_TABLE)
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"FileListV2/common"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlobIdMapping_AddWithBsName_WritesBlobRefsAndSql(t *testing.T) {
+	common.BsName = "default"
+	defer func() { common.BsName = "" }()
+	dir := t.TempDir()
+	mapPath, sqlPath := filepath.Join(dir, "mapping.tsv"), filepath.Join(dir, "update.sql")
+	m, err := OpenBlobIdMapping(mapPath, sqlPath, "raw-copied")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add("11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"))
+	assert.NoError(t, m.Add("33333333-3333-3333-3333-333333333333@2025-08-14T02:44", "44444444-4444-4444-4444-444444444444@2025-08-14T02:44"))
+	assert.NoError(t, m.Close())
+
+	mapping, _ := os.ReadFile(mapPath)
+	lines := strings.Split(strings.TrimSpace(string(mapping)), "\n")
+	assert.Equal(t, []string{
+		"OldBlobRef\tNewBlobRef",
+		"default@11111111-1111-1111-1111-111111111111\tdefault@22222222-2222-2222-2222-222222222222",
+		"default@33333333-3333-3333-3333-333333333333@2025-08-14T02:44\tdefault@44444444-4444-4444-4444-444444444444@2025-08-14T02:44",
+	}, lines)
+
+	sqlBytes, _ := os.ReadFile(sqlPath)
+	sqlText := string(sqlBytes)
+	assert.True(t, strings.HasPrefix(sqlText, "-- Generated by filelist2"))
+	assert.Contains(t, sqlText, "INSERT INTO "+BLOB_ID_MAP_TABLE+" VALUES ('11111111-1111-1111-1111-111111111111', '22222222-2222-2222-2222-222222222222');")
+	assert.Contains(t, sqlText, "WHERE name = 'raw-copied';")
+	assert.Contains(t, sqlText, "SUBSTRING(ab.blob_ref FROM POSITION(''@'' IN ab.blob_ref) + 1) = m.old_id'")
+	assert.NotContains(t, sqlText, "LIKE")
+	assert.True(t, strings.HasSuffix(sqlText, "END $$;\nCOMMIT;\n"))
+}
+
+func TestBlobIdMapping_NoSqlPath_WritesOnlyMapping(t *testing.T) {
+	dir := t.TempDir()
+	m, err := OpenBlobIdMapping(filepath.Join(dir, "mapping.tsv"), "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, m.Add("11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"))
+	assert.NoError(t, m.Close())
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	// No -bsName, so the blob IDs
+	mapping, _ := os.ReadFile(filepath.Join(dir, "mapping.tsv"))
+	assert.Equal(t, "OldBlobId\tNewBlobId\n11111111-1111-1111-1111-111111111111\t22222222-2222-2222-2222-222222222222\n", string(mapping))
+}
@@ -0,0 +1,99 @@
+// Package lib: bulk orphaned blob check (-dbBulk) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// blobRefEntry is the repository (index of BlobRefSet.repos, or -1 if in multiple repositories) and asset path of one blob
+type blobRefEntry struct {
+	repoIdx int32
+	path    string
+}
+
+// BlobRefSet holds the blob IDs (as 16 bytes) of the asset blobs loaded from the DB, to check the orphaned blobs without the query per blob.
+// Not thread safe for Add, but Lookup can be used concurrently after loaded.
+type BlobRefSet struct {
+	LoadedAt  time.Time // The blobs created after this time may not be in this set
+	Fallbacks int64     // Atomic. Number of the ambiguous blobs checked with SQL
+	refs      map[[16]byte]blobRefEntry
+	repos     []string
+	repoIdx   map[string]int32
+	withPath  bool
+}
+
+// NewBlobRefSet creates an empty set. If withPath is true, the asset path is also kept (for the blob name check)
+func NewBlobRefSet(withPath bool) *BlobRefSet {
+	return &BlobRefSet{
+		LoadedAt: time.Now(),
+		refs:     make(map[[16]byte]blobRefEntry),
+		repoIdx:  make(map[string]int32),
+		withPath: withPath,
+	}
+}
+
+// ParseBlobUuid returns the 16 bytes of the first UUID in the blob ID, blob ref or path
+func ParseBlobUuid(s string) ([16]byte, bool) {
+	var uuid [16]byte
+	found := common.RxBlobId.FindString(s)
+	if len(found) == 0 {
+		return uuid, false
+	}
+	if _, err := hex.Decode(uuid[:], []byte(strings.ReplaceAll(found, "-", ""))); err != nil {
+		return uuid, false
+	}
+	return uuid, true
+}
+
+// Add adds the blob ref (eg. 'default@UUID@yyyy-MM-ddTHH:mm') of the repository. Returns false if no blob ID in blobRef.
+func (s *BlobRefSet) Add(repoName string, blobRef string, path string) bool {
+	uuid, ok := ParseBlobUuid(blobRef)
+	if !ok {
+		return false
+	}
+	idx, ok := s.repoIdx[repoName]
+	if !ok {
+		idx = int32(len(s.repos))
+		s.repos = append(s.repos, repoName)
+		s.repoIdx[repoName] = idx
+	}
+	if !s.withPath {
+		path = ""
+	}
+	if existing, ok := s.refs[uuid]; ok && existing.repoIdx != idx {
+		// Same blob in another repository
+		idx = -1
+	}
+	s.refs[uuid] = blobRefEntry{repoIdx: idx, path: path}
+	return true
+}
+
+// Lookup returns the repository name (empty if in multiple repositories) and the asset path of the blob ID
+func (s *BlobRefSet) Lookup(blobId string) (repoName string, path string, found bool) {
+	uuid, ok := ParseBlobUuid(blobId)
+	if !ok {
+		return "", "", false
+	}
+	entry, ok := s.refs[uuid]
+	if !ok {
+		return "", "", false
+	}
+	if entry.repoIdx >= 0 {
+		repoName = s.repos[entry.repoIdx]
+	}
+	return repoName, entry.path, true
+}
+
+// Len returns the number of the blob IDs
+func (s *BlobRefSet) Len() int {
+	return len(s.refs)
+}
+
+// CountFallback increments the number of the ambiguous blobs checked with SQL
+func (s *BlobRefSet) CountFallback() {
+	atomic.AddInt64(&s.Fallbacks, 1)
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBlobRefSet_Lookup_ReturnsRepoAndPath(t *testing.T) {
+	refs := NewBlobRefSet(true)
+	assert.True(t, refs.Add("raw-hosted", "default@11111111-1111-1111-1111-111111111111", "/a.txt"))
+	assert.True(t, refs.Add("maven-proxy", "default@22222222-2222-2222-2222-222222222222@2024-01-02T03:04", "/b.jar"))
+	assert.False(t, refs.Add("raw-hosted", "default@not-a-blob-id", "/c.txt"))
+	assert.Equal(t, 2, refs.Len())
+
+	repoName, path, found := refs.Lookup("11111111-1111-1111-1111-111111111111")
+	assert.True(t, found)
+	assert.Equal(t, "raw-hosted", repoName)
+	assert.Equal(t, "/a.txt", path)
+
+	// The blob ID with the date (new blob store layout) and the path also work
+	repoName, path, found = refs.Lookup("/2024/01/02/03/04/22222222-2222-2222-2222-222222222222.properties")
+	assert.True(t, found)
+	assert.Equal(t, "maven-proxy", repoName)
+	assert.Equal(t, "/b.jar", path)
+
+	_, _, found = refs.Lookup("33333333-3333-3333-3333-333333333333")
+	assert.False(t, found)
+}
+
+func TestBlobRefSet_Add_SameBlobInTwoRepos_ReturnsEmptyRepo(t *testing.T) {
+	refs := NewBlobRefSet(false)
+	refs.Add("raw-hosted", "default@11111111-1111-1111-1111-111111111111", "/a.txt")
+	refs.Add("raw-group", "default@11111111-1111-1111-1111-111111111111", "/a.txt")
+	repoName, path, found := refs.Lookup("11111111-1111-1111-1111-111111111111")
+	assert.True(t, found)
+	assert.Empty(t, repoName)
+	// withPath is false
+	assert.Empty(t, path)
+	refs.CountFallback()
+	assert.Equal(t, int64(1), refs.Fallbacks)
+}
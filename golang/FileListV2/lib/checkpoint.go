@@ -0,0 +1,202 @@
+// Package lib: checkpoint file related functions, to resume the long listing.
+package lib
+
+import (
+	"FileListV2/common"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const CHECKPOINT_EXT = ".checkpoint"
+
+// Checkpoint records the completed units (eg. sub-directories) one per line
+type Checkpoint struct {
+	Path string
+	mu   sync.Mutex
+	file *os.File
+	done map[string]bool
+}
+
+// OpenCheckpoint opens the checkpoint file. If resume is true, the completed units are loaded, otherwise the file is truncated.
+func OpenCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	c := &Checkpoint{Path: path, done: make(map[string]bool)}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		f, err := os.Open(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if len(line) > 0 {
+					c.done[line] = true
+				}
+			}
+			_ = f.Close()
+			if err = scanner.Err(); err != nil {
+				return nil, fmt.Errorf("reading checkpoint %s failed: %s", path, err.Error())
+			}
+		}
+	} else {
+		flags = flags | os.O_TRUNC
+	}
+	var err error
+	c.file, err = os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DoneCount returns the number of the completed units
+func (c *Checkpoint) DoneCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.done)
+}
+
+// IsDone returns true if the unit is recorded as completed
+func (c *Checkpoint) IsDone(unit string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[unit]
+}
+
+// MarkDone records the unit as completed. Writing per unit, so that the file is up-to-date even if the process is killed.
+func (c *Checkpoint) MarkDone(unit string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done[unit] {
+		return nil
+	}
+	c.done[unit] = true
+	_, err := fmt.Fprintln(c.file, unit)
+	return err
+}
+
+// Close closes the checkpoint file. If remove is true (eg. completed successfully), the file is deleted.
+func (c *Checkpoint) Close(remove bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.file.Close()
+	if remove {
+		return os.Remove(c.Path)
+	}
+	return err
+}
+
+// RemoveLinesUnder rewrites the output file (tsv, csv or jsonl) without the lines which Path is under one of the dirs.
+// If member is not empty (group blob store), only the lines of this member are removed.
+// Used by -resume, as the interrupted directories are listed again, and their lines would be duplicated. Returns the number of the removed lines.
+func RemoveLinesUnder(outPath string, dirs map[string]bool, member string, format string) (int64, error) {
+	in, err := os.Open(outPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var removed int64
+	writer := bufio.NewWriter(tmp)
+	reader := bufio.NewReader(in)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if len(line) > 0 {
+			path, cols := lineColumns(strings.TrimRight(line, "\r\n"), format)
+			if isUnderDirs(path, dirs) && (len(member) == 0 || hasColumn(cols, member)) {
+				removed++
+			} else if _, err = writer.WriteString(line); err != nil {
+				return 0, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return 0, readErr
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if err = writer.Flush(); err != nil {
+		return 0, err
+	}
+	// Overwriting the contents instead of renaming, as the output file may be already opened with O_APPEND
+	out, err := os.OpenFile(outPath, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err = io.Copy(out, tmp); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// lineColumns returns the Path (first column) and all columns of the output line
+func lineColumns(line string, format string) (string, []string) {
+	var cols []string
+	switch format {
+	case "csv":
+		cols, _ = csv.NewReader(strings.NewReader(line)).Read()
+	case "jsonl":
+		record := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return "", nil
+		}
+		path, _ := record["Path"].(string)
+		member, _ := record["Member"].(string)
+		return path, []string{path, member}
+	default:
+		cols = strings.Split(line, common.SEP)
+	}
+	if len(cols) == 0 {
+		return "", nil
+	}
+	return cols[0], cols
+}
+
+// isUnderDirs returns true if one of the parent directories of the path is in the dirs (without the trailing separator)
+func isUnderDirs(path string, dirs map[string]bool) bool {
+	for {
+		idx := strings.LastIndexAny(path, "/"+string(filepath.Separator))
+		if idx <= 0 {
+			return false
+		}
+		path = path[:idx]
+		if dirs[path] {
+			return true
+		}
+	}
+}
+
+func hasColumn(cols []string, value string) bool {
+	for _, col := range cols {
+		if col == value {
+			return true
+		}
+	}
+	return false
+}
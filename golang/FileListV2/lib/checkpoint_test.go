@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint_MarkDoneThenResume_SkipsCompletedUnits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filelist.tsv"+CHECKPOINT_EXT)
+	c, err := OpenCheckpoint(path, false)
+	assert.NoError(t, err)
+	assert.NoError(t, c.MarkDone("/blobs/default/content/vol-01/chap-01"))
+	assert.NoError(t, c.MarkDone("/blobs/default/content/vol-01/chap-02"))
+	assert.NoError(t, c.MarkDone("/blobs/default/content/vol-01/chap-02"))
+	assert.NoError(t, c.Close(false))
+
+	c, err = OpenCheckpoint(path, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, c.DoneCount())
+	assert.True(t, c.IsDone("/blobs/default/content/vol-01/chap-01"))
+	assert.False(t, c.IsDone("/blobs/default/content/vol-01/chap-03"))
+	assert.NoError(t, c.MarkDone("/blobs/default/content/vol-01/chap-03"))
+	assert.NoError(t, c.Close(false))
+
+	contents, _ := os.ReadFile(path)
+	assert.Equal(t, "/blobs/default/content/vol-01/chap-01\n/blobs/default/content/vol-01/chap-02\n/blobs/default/content/vol-01/chap-03\n", string(contents))
+}
+
+func TestCheckpoint_NotResume_TruncatesAndRemoves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filelist.tsv"+CHECKPOINT_EXT)
+	_ = os.WriteFile(path, []byte("/old/dir\n"), 0644)
+	c, err := OpenCheckpoint(path, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.DoneCount())
+	assert.NoError(t, c.Close(true))
+	assert.NoFileExists(t, path)
+}
+
+func TestRemoveLinesUnder_InterruptedDir_RemovesOnlyItsLines(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "filelist.tsv")
+	_ = os.WriteFile(outPath, []byte("Path\tLastModified\tSize\n"+
+		"content/vol-01/chap-01/a.properties\t2024\t1\n"+
+		"content/vol-01/chap-02/b.properties\t2024\t2\n"+
+		"content/vol-01/chap-02x/c.properties\t2024\t3\n"), 0644)
+	// Opened with O_APPEND like -s, and still appending after rewriting
+	f, err := os.OpenFile(outPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	removed, err := RemoveLinesUnder(outPath, map[string]bool{"content/vol-01/chap-02": true}, "", "tsv")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	_, _ = f.WriteString("content/vol-01/chap-02/b.properties\t2024\t2\n")
+	contents, _ := os.ReadFile(outPath)
+	assert.Equal(t, "Path\tLastModified\tSize\n"+
+		"content/vol-01/chap-01/a.properties\t2024\t1\n"+
+		"content/vol-01/chap-02x/c.properties\t2024\t3\n"+
+		"content/vol-01/chap-02/b.properties\t2024\t2\n", string(contents))
+}
+
+func TestRemoveLinesUnder_GroupMember_RemovesOnlyMemberLines(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "filelist.jsonl")
+	_ = os.WriteFile(outPath, []byte(`{"Member":"s3://a/p","Path":"content/vol-01/chap-01/a.properties"}`+"\n"+
+		`{"Member":"s3://b/p","Path":"content/vol-01/chap-01/a.properties"}`+"\n"), 0644)
+	removed, err := RemoveLinesUnder(outPath, map[string]bool{"content/vol-01/chap-01": true}, "s3://b/p", "jsonl")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	contents, _ := os.ReadFile(outPath)
+	assert.Equal(t, `{"Member":"s3://a/p","Path":"content/vol-01/chap-01/a.properties"}`+"\n", string(contents))
+
+	removed, err = RemoveLinesUnder(filepath.Join(t.TempDir(), "not-exist.tsv"), map[string]bool{"content": true}, "", "tsv")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), removed)
+}
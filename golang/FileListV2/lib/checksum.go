@@ -0,0 +1,64 @@
+// Package lib: checksum (-bTo-Verify and -integrity) related functions.
+package lib
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const INTEGRITY_SIZE_MISMATCH = "INTEGRITY_SIZE_MISMATCH"
+const INTEGRITY_SHA1_MISMATCH = "INTEGRITY_SHA1_MISMATCH"
+const INTEGRITY_NO_SIZE = "INTEGRITY_NO_SIZE"
+const INTEGRITY_NO_SHA1 = "INTEGRITY_NO_SHA1"
+const INTEGRITY_READ_ERROR = "INTEGRITY_READ_ERROR"
+
+// HashReader reads all from the reader and returns the hex encoded hashes per algorithm ('sha1' or 'sha256') and the read size
+func HashReader(reader io.Reader, algorithms ...string) (map[string]string, int64, error) {
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		var hasher hash.Hash
+		switch algorithm {
+		case "sha1":
+			hasher = sha1.New()
+		case "sha256":
+			hasher = sha256.New()
+		default:
+			return nil, 0, fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+		}
+		hashes[algorithm] = hasher
+		writers = append(writers, hasher)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), reader)
+	if err != nil {
+		return nil, size, err
+	}
+	results := make(map[string]string, len(hashes))
+	for algorithm, hasher := range hashes {
+		results[algorithm] = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return results, size, nil
+}
+
+// CheckIntegrity reads the .bytes contents from the reader and compares the size and sha1 with the values in the .properties.
+// Returns the INTEGRITY_* code with the details, or empty string if matched.
+func CheckIntegrity(reader io.Reader, sizeInProps int64, sha1InProps string) string {
+	hashes, size, err := HashReader(reader, "sha1")
+	if err != nil {
+		return INTEGRITY_READ_ERROR + ":" + err.Error()
+	}
+	if size != sizeInProps {
+		return fmt.Sprintf("%s:size=%d|bytes=%d", INTEGRITY_SIZE_MISMATCH, sizeInProps, size)
+	}
+	if len(sha1InProps) == 0 {
+		return INTEGRITY_NO_SHA1
+	}
+	if hashes["sha1"] != sha1InProps {
+		return fmt.Sprintf("%s:sha1=%s|bytes=%s", INTEGRITY_SHA1_MISMATCH, sha1InProps, hashes["sha1"])
+	}
+	return ""
+}
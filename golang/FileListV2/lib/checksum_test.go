@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestHashReader_Sha1AndSha256_ReturnsHexHashes(t *testing.T) {
+	hashes, size, err := HashReader(strings.NewReader("abc"), "sha1", "sha256")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), size)
+	assert.Equal(t, "a9993e364706816aba3e25717850c26c9cd0d89d", hashes["sha1"])
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", hashes["sha256"])
+}
+
+func TestHashReader_UnsupportedAlgorithm_ReturnsError(t *testing.T) {
+	_, _, err := HashReader(strings.NewReader("abc"), "md5")
+	assert.Error(t, err)
+}
+
+func TestCheckIntegrity_Mismatch_ReturnsCode(t *testing.T) {
+	sha1Abc := "a9993e364706816aba3e25717850c26c9cd0d89d"
+	assert.Empty(t, CheckIntegrity(strings.NewReader("abc"), 3, sha1Abc))
+	assert.Equal(t, INTEGRITY_SIZE_MISMATCH+":size=4|bytes=3", CheckIntegrity(strings.NewReader("abc"), 4, sha1Abc))
+	assert.Equal(t, INTEGRITY_SHA1_MISMATCH+":sha1="+sha1Abc+"|bytes=cb4cc28df0fdbe0ecf9d9662e294b118092a5735", CheckIntegrity(strings.NewReader("abd"), 3, sha1Abc))
+	assert.Equal(t, INTEGRITY_NO_SHA1, CheckIntegrity(strings.NewReader("abc"), 3, ""))
+}
@@ -0,0 +1,107 @@
+// Package lib: config file ($HOME/.filelist_config) related functions.
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const CONFIG_FILE_NAME = ".filelist_config"
+const DEFAULT_PROFILE = "default"
+
+// DefaultConfigPath returns $HOME/.filelist_config (empty if the home directory is unknown)
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil || len(home) == 0 {
+		return ""
+	}
+	return filepath.Join(home, CONFIG_FILE_NAME)
+}
+
+// ReadConfigProfiles reads INI like config file. The lines before any [section] belong to the "default" profile.
+// Keys are the flag names (with or without the leading '-'), eg:
+//
+//	c=4
+//	[prod-s3]
+//	b=s3://prod-bucket/prefix/
+//	PathStyle=true
+func ReadConfigProfiles(configPath string) (map[string]map[string]string, error) {
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	profile := DEFAULT_PROFILE
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			profile = strings.TrimSpace(line[1 : len(line)-1])
+			if len(profile) == 0 {
+				return nil, fmt.Errorf("%s:%d empty profile name", configPath, lineNum)
+			}
+			if _, ok := profiles[profile]; !ok {
+				profiles[profile] = map[string]string{}
+			}
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s:%d is not 'key=value': %s", configPath, lineNum, line)
+		}
+		key := strings.TrimLeft(strings.TrimSpace(kv[0]), "-")
+		if len(key) == 0 {
+			return nil, fmt.Errorf("%s:%d empty key", configPath, lineNum)
+		}
+		if _, ok := profiles[profile]; !ok {
+			profiles[profile] = map[string]string{}
+		}
+		profiles[profile][key] = unquote(strings.TrimSpace(kv[1]))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// GetConfigProfile returns the key/values of the profile, merged on top of the "default" profile.
+func GetConfigProfile(configPath string, profile string) (map[string]string, error) {
+	profiles, err := ReadConfigProfiles(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(profile) == 0 {
+		profile = DEFAULT_PROFILE
+	}
+	selected, ok := profiles[profile]
+	if !ok && profile != DEFAULT_PROFILE {
+		return nil, fmt.Errorf("profile '%s' is not found in %s", profile, configPath)
+	}
+	result := map[string]string{}
+	for k, v := range profiles[DEFAULT_PROFILE] {
+		result[k] = v
+	}
+	for k, v := range selected {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
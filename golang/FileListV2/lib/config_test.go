@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, contents string) string {
+	configPath := filepath.Join(t.TempDir(), CONFIG_FILE_NAME)
+	err := os.WriteFile(configPath, []byte(contents), 0600)
+	assert.NoError(t, err)
+	return configPath
+}
+
+const TEST_CONFIG = `# comment line
+c=4
+-c2 = 16
+
+[prod-s3]
+b=s3://prod-bucket/prefix/
+PathStyle=true
+c=8
+
+[dr-azure]
+b = "az://dr-container/prefix/"
+; another comment
+bsName='dr'
+`
+
+func TestGetConfigProfile_NamedProfile_OverridesDefault(t *testing.T) {
+	configPath := writeTestConfig(t, TEST_CONFIG)
+	values, err := GetConfigProfile(configPath, "prod-s3")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c": "8", "c2": "16", "b": "s3://prod-bucket/prefix/", "PathStyle": "true"}, values)
+
+	values, err = GetConfigProfile(configPath, "dr-azure")
+	assert.NoError(t, err)
+	assert.Equal(t, "az://dr-container/prefix/", values["b"])
+	assert.Equal(t, "dr", values["bsName"])
+	assert.Equal(t, "4", values["c"])
+}
+
+func TestGetConfigProfile_NoProfile_ReturnsDefaultOnly(t *testing.T) {
+	configPath := writeTestConfig(t, TEST_CONFIG)
+	values, err := GetConfigProfile(configPath, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c": "4", "c2": "16"}, values)
+}
+
+func TestGetConfigProfile_MissingProfile_ReturnsError(t *testing.T) {
+	configPath := writeTestConfig(t, TEST_CONFIG)
+	_, err := GetConfigProfile(configPath, "not-exist")
+	assert.Error(t, err)
+}
+
+func TestReadConfigProfiles_InvalidLine_ReturnsError(t *testing.T) {
+	configPath := writeTestConfig(t, "[prod]\nthis is not key value\n")
+	_, err := ReadConfigProfiles(configPath)
+	assert.Error(t, err)
+}
@@ -8,16 +8,21 @@ import (
 	h "github.com/hajimeo/samples/golang/helpers"
 	_ "github.com/lib/pq"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
 	"time"
 )
 
-func GenDbConnStrFromFile(filePath string) string {
+// GenDbConnStrFromFile returns the DB connection string and the DB type from nexus-store.properties
+func GenDbConnStrFromFile(filePath string) (string, string) {
 	props, _ := h.ReadPropertiesFile(filePath)
 	jdbcPtn := regexp.MustCompile(`jdbc:postgresql://([^/:]+):?(\d*)/([^?]+)\??(.*)`)
 	props["jdbcUrl"] = strings.ReplaceAll(props["jdbcUrl"], "\\", "")
+	if strings.HasPrefix(props["jdbcUrl"], "jdbc:h2:") {
+		return genH2ConnStr(props), DB_TYPE_H2
+	}
 	matches := jdbcPtn.FindStringSubmatch(props["jdbcUrl"])
 	if matches == nil {
 		props["password"] = "********"
@@ -52,7 +57,20 @@ func GenDbConnStrFromFile(filePath string) string {
 	}
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s%s", hostname, port, props["username"], props["password"], database, params)
 	h.Log("INFO", fmt.Sprintf("host=%s port=%s user=%s password=******** dbname=%s%s", hostname, port, props["username"], database, params))
-	return connStr
+	return connStr, DB_TYPE_POSTGRES
+}
+
+// genH2ConnStr returns the connection string to the H2 PG server (default port 5435, or H2_PG_PORT), as no Go driver for H2.
+// The database name is the H2 file name without '.mv.db' (eg. 'jdbc:h2:file:${karaf.data}/db/nexus' => 'nexus')
+func genH2ConnStr(props map[string]string) string {
+	database := strings.TrimSuffix(filepath.Base(strings.SplitN(props["jdbcUrl"], ";", 2)[0]), ".mv.db")
+	user := props["username"]
+	if len(user) == 0 {
+		user = "sa"
+	}
+	port := h.GetEnv("H2_PG_PORT", "5435")
+	h.Log("INFO", fmt.Sprintf("H2 database '%s' needs to be accessible with the PG server mode (eg. java -cp h2.jar org.h2.tools.Server -pg -pgPort %s -baseDir <dir of %s.mv.db>)", database, port, database))
+	return fmt.Sprintf("host=localhost port=%s user=%s password=%s dbname=%s", port, user, props["password"], database)
 }
 
 func OpenDb(dbConnStr string) *sql.DB {
@@ -60,10 +78,15 @@ func OpenDb(dbConnStr string) *sql.DB {
 		h.Log("DEBUG", "Empty DB connection string")
 		return nil
 	}
-	if !strings.Contains(dbConnStr, "sslmode") {
+	dialect := GetDialect()
+	if dialect.DriverName() == "postgres" && !strings.Contains(dbConnStr, "sslmode") {
 		dbConnStr = dbConnStr + " sslmode=disable"
 	}
-	db, err := sql.Open("postgres", dbConnStr)
+	if dialect.Name() == DB_TYPE_SQLITE && !strings.HasPrefix(dbConnStr, "file:") {
+		// Not modifying the imported DB
+		dbConnStr = "file:" + dbConnStr + "?mode=ro"
+	}
+	db, err := sql.Open(dialect.DriverName(), dbConnStr)
 	if err != nil {
 		// If DB connection issue, let's stop the script
 		panic(err.Error())
@@ -92,6 +115,58 @@ func Query(query string, db *sql.DB, slowMs int64) *sql.Rows {
 	return rows
 }
 
+// QueryWithCursor calls perRow for each row until perRow returns false. With PostgreSQL, the server side cursor is used
+// to fetch fetchSize rows at once, so that the large result is not sent to the client at once. Other DB types just iterate the rows.
+func QueryWithCursor(query string, db *sql.DB, fetchSize int, perRow func(rows *sql.Rows) bool) error {
+	h.Log("DEBUG", h.TruncateStr("Cursor query: "+query, 1000))
+	if GetDialect().Name() != DB_TYPE_POSTGRES {
+		rows, err := db.Query(query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if !perRow(rows) {
+				return nil
+			}
+		}
+		return rows.Err()
+	}
+
+	// The cursor requires the transaction (read only is OK)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("DECLARE filelist2_cursor NO SCROLL CURSOR FOR " + query); err != nil {
+		return err
+	}
+	fetchQuery := fmt.Sprintf("FETCH %d FROM filelist2_cursor", fetchSize)
+	for {
+		rows, err := tx.Query(fetchQuery)
+		if err != nil {
+			return err
+		}
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			if !perRow(rows) {
+				_ = rows.Close()
+				return nil
+			}
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+		if fetched < fetchSize {
+			return nil
+		}
+	}
+}
+
 func GetRow(rowCur *sql.Rows, cols []string) []interface{} {
 	if cols == nil || len(cols) == 0 {
 		h.Log("ERROR", "No column information")
@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueryWithCursor_StopsWhenPerRowReturnsFalse(t *testing.T) {
+	path := createTestSqliteDb(t)
+	assert.NoError(t, SetDialect(DB_TYPE_SQLITE))
+	defer SetDialect("")
+	db := OpenDb(path)
+	defer db.Close()
+
+	var paths []string
+	err := QueryWithCursor("SELECT path FROM raw_asset ORDER BY path", db, 1, func(rows *sql.Rows) bool {
+		var path string
+		assert.NoError(t, rows.Scan(&path))
+		paths = append(paths, path)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/a.txt", "/c.txt"}, paths)
+
+	count := 0
+	err = QueryWithCursor("SELECT path FROM raw_asset", db, 1, func(rows *sql.Rows) bool {
+		count++
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.Error(t, QueryWithCursor("SELECT path FROM not_exist", db, 1, func(rows *sql.Rows) bool { return true }))
+}
@@ -0,0 +1,189 @@
+// Package lib: DB dialect (PostgreSQL, H2, SQLite) related functions.
+package lib
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"os"
+	"slices"
+	"strings"
+)
+
+const DB_TYPE_POSTGRES = "postgres"
+const DB_TYPE_H2 = "h2"
+const DB_TYPE_SQLITE = "sqlite"
+
+// Dialect : The differences of the SQL between the databases which contain the Nexus Repository tables
+// (repository, {format}_content_repository, {format}_asset, {format}_asset_blob)
+type Dialect interface {
+	// Name : DB_TYPE_POSTGRES, DB_TYPE_H2 or DB_TYPE_SQLITE
+	Name() string
+	// DriverName : The database/sql driver name
+	DriverName() string
+	// RepoFormatQuery : The query which returns the repository name and the format, only for the blob store if bsName is given
+	RepoFormatQuery(bsName string) string
+	// AssetBlobQuery : The query which returns 'repo_name' and the columns for the repositories of one format
+	AssetBlobQuery(format string, columns string, repoNames []string, afterWhere string) string
+	// UnionAll : Combine the queries of AssetBlobQuery
+	UnionAll(queries []string) string
+}
+
+var currentDialect Dialect = postgresDialect{}
+
+// SetDialect changes the dialect used by OpenDb and GetDialect (default: PostgreSQL)
+func SetDialect(dbType string) error {
+	switch strings.ToLower(dbType) {
+	case "", DB_TYPE_POSTGRES, "postgresql", "pg":
+		currentDialect = postgresDialect{}
+	case DB_TYPE_H2:
+		currentDialect = h2Dialect{}
+	case DB_TYPE_SQLITE, "sqlite3":
+		currentDialect = sqliteDialect{}
+	default:
+		return fmt.Errorf("unsupported DB type: %s (postgres, h2 or sqlite)", dbType)
+	}
+	if !slices.Contains(sql.Drivers(), currentDialect.DriverName()) {
+		return fmt.Errorf("no driver %s for DB type: %s", currentDialect.DriverName(), dbType)
+	}
+	return nil
+}
+
+// GetDialect returns the current dialect
+func GetDialect() Dialect {
+	return currentDialect
+}
+
+// IsSqliteFile returns true if the path is an SQLite database file (checking the header)
+func IsSqliteFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, 16)
+	n, _ := f.Read(header)
+	return n == 16 && bytes.Equal(header, []byte("SQLite format 3\x00"))
+}
+
+func repoInList(repoNames []string) string {
+	return `'` + strings.Join(repoNames, `','`) + `'`
+}
+
+// REGEXP_REPLACE and '->>' (jsonb) are used.
+type postgresDialect struct{}
+
+func (d postgresDialect) Name() string {
+	return DB_TYPE_POSTGRES
+}
+
+func (d postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (d postgresDialect) RepoFormatQuery(bsName string) string {
+	query := "SELECT name, REGEXP_REPLACE(recipe_name, '-.+', '') AS fmt FROM repository"
+	if len(bsName) > 0 {
+		query += " WHERE attributes->'storage'->>'blobStoreName' = '" + bsName + "'"
+	}
+	return query
+}
+
+func (d postgresDialect) AssetBlobQuery(format string, columns string, repoNames []string, afterWhere string) string {
+	q := "WITH r AS (select r.name, cr.repository_id from " + format + "_content_repository cr join repository r on r.id = cr.config_repository_id WHERE r.name IN (" + repoInList(repoNames) + ")) "
+	q = q + "SELECT r.name as repo_name, " + columns
+	q = q + " FROM " + format + "_asset_blob ab"
+	// NOTE: Due to the performance concern, NOT using LEFT JOIN even though this script may find orphaned blobs when Cleanup unused asset blob tasks aren't run yet.
+	q = q + " JOIN " + format + "_asset a USING (asset_blob_id) JOIN r USING (repository_id)"
+	q = q + " WHERE 1=1 " + afterWhere
+	return q
+}
+
+func (d postgresDialect) UnionAll(queries []string) string {
+	if len(queries) == 1 {
+		return queries[0]
+	}
+	if len(queries) > 1 {
+		return "(" + strings.Join(queries, ") UNION ALL (") + ")"
+	}
+	return ""
+}
+
+// genericAssetBlobQuery uses only JOIN ... ON (no CTE and no USING), which works with H2 and SQLite
+func genericAssetBlobQuery(format string, columns string, repoNames []string, afterWhere string) string {
+	q := "SELECT r.name as repo_name, " + columns
+	q = q + " FROM " + format + "_asset_blob ab"
+	q = q + " JOIN " + format + "_asset a ON a.asset_blob_id = ab.asset_blob_id"
+	q = q + " JOIN " + format + "_content_repository cr ON cr.repository_id = a.repository_id"
+	q = q + " JOIN repository r ON r.id = cr.config_repository_id"
+	q = q + " WHERE r.name IN (" + repoInList(repoNames) + ") " + afterWhere
+	return q
+}
+
+// genericUnionAll uses the sub queries, as the parenthesized query with LIMIT is not allowed in SQLite
+func genericUnionAll(queries []string) string {
+	if len(queries) == 1 {
+		return queries[0]
+	}
+	subQueries := make([]string, len(queries))
+	for i, q := range queries {
+		subQueries[i] = fmt.Sprintf("SELECT * FROM (%s) u%d", q, i)
+	}
+	return strings.Join(subQueries, " UNION ALL ")
+}
+
+// H2 (embedded DB of the older Nexus) through the PG server mode (eg. 'java -cp h2.jar org.h2.tools.Server -pg -baseDir ./db'),
+// so using lib/pq. The attributes column is JSON (or the text in the export), so matching with the regex.
+type h2Dialect struct{}
+
+func (d h2Dialect) Name() string {
+	return DB_TYPE_H2
+}
+
+func (d h2Dialect) DriverName() string {
+	return "postgres"
+}
+
+func (d h2Dialect) RepoFormatQuery(bsName string) string {
+	query := "SELECT name, REGEXP_REPLACE(recipe_name, '-.+', '') AS fmt FROM repository"
+	if len(bsName) > 0 {
+		query += " WHERE REGEXP_LIKE(CAST(attributes AS VARCHAR), '\"blobStoreName\" *: *\"" + bsName + "\"')"
+	}
+	return query
+}
+
+func (d h2Dialect) AssetBlobQuery(format string, columns string, repoNames []string, afterWhere string) string {
+	return genericAssetBlobQuery(format, columns, repoNames, afterWhere)
+}
+
+func (d h2Dialect) UnionAll(queries []string) string {
+	return genericUnionAll(queries)
+}
+
+// SQLite (eg. the tables imported from the support bundle or the H2 export). No REGEXP_REPLACE, but json_extract.
+type sqliteDialect struct{}
+
+func (d sqliteDialect) Name() string {
+	return DB_TYPE_SQLITE
+}
+
+func (d sqliteDialect) DriverName() string {
+	return "sqlite"
+}
+
+func (d sqliteDialect) RepoFormatQuery(bsName string) string {
+	query := "SELECT name, CASE WHEN INSTR(recipe_name, '-') > 0 THEN SUBSTR(recipe_name, 1, INSTR(recipe_name, '-') - 1) ELSE recipe_name END AS fmt FROM repository"
+	if len(bsName) > 0 {
+		query += " WHERE json_extract(attributes, '$.storage.blobStoreName') = '" + bsName + "'"
+	}
+	return query
+}
+
+func (d sqliteDialect) AssetBlobQuery(format string, columns string, repoNames []string, afterWhere string) string {
+	return genericAssetBlobQuery(format, columns, repoNames, afterWhere)
+}
+
+func (d sqliteDialect) UnionAll(queries []string) string {
+	return genericUnionAll(queries)
+}
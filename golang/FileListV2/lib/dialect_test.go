@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestSetDialect_Unknown_ReturnsError(t *testing.T) {
+	assert.Error(t, SetDialect("mysql"))
+	assert.NoError(t, SetDialect("H2"))
+	assert.Equal(t, DB_TYPE_H2, GetDialect().Name())
+	assert.NoError(t, SetDialect(""))
+	assert.Equal(t, DB_TYPE_POSTGRES, GetDialect().Name())
+}
+
+func TestH2Dialect_RepoFormatQuery_UsesRegexForAttributes(t *testing.T) {
+	query := h2Dialect{}.RepoFormatQuery("default")
+	assert.Contains(t, query, "REGEXP_LIKE(CAST(attributes AS VARCHAR), '\"blobStoreName\" *: *\"default\"')")
+	assert.NotContains(t, query, "->>")
+}
+
+func TestGenDbConnStrFromFile_H2_ReturnsPgServerConnStr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nexus-store.properties")
+	assert.NoError(t, os.WriteFile(path, []byte("jdbcUrl=jdbc\\:h2\\:file\\:${karaf.data}/db/nexus;DB_CLOSE_ON_EXIT=FALSE\nusername=\npassword=\n"), 0644))
+	connStr, dbType := GenDbConnStrFromFile(path)
+	assert.Equal(t, DB_TYPE_H2, dbType)
+	assert.Equal(t, "host=localhost port=5435 user=sa password= dbname=nexus", connStr)
+}
+
+func createTestSqliteDb(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "nexus.db")
+	db, err := sql.Open("sqlite", path)
+	assert.NoError(t, err)
+	defer db.Close()
+	stmts := []string{
+		"CREATE TABLE repository (id INTEGER PRIMARY KEY, name TEXT, recipe_name TEXT, attributes TEXT)",
+		"INSERT INTO repository VALUES (1, 'raw-hosted', 'raw-hosted', '{\"storage\":{\"blobStoreName\":\"default\"}}')",
+		"INSERT INTO repository VALUES (2, 'maven-proxy', 'maven2-proxy', '{\"storage\":{\"blobStoreName\":\"default\"}}')",
+		"INSERT INTO repository VALUES (3, 'raw-other', 'raw-hosted', '{\"storage\":{\"blobStoreName\":\"other\"}}')",
+	}
+	for _, format := range []string{"raw", "maven2"} {
+		stmts = append(stmts,
+			"CREATE TABLE "+format+"_content_repository (repository_id INTEGER PRIMARY KEY, config_repository_id INTEGER)",
+			"CREATE TABLE "+format+"_asset (asset_id INTEGER PRIMARY KEY, repository_id INTEGER, path TEXT, asset_blob_id INTEGER)",
+			"CREATE TABLE "+format+"_asset_blob (asset_blob_id INTEGER PRIMARY KEY, blob_ref TEXT)")
+	}
+	stmts = append(stmts,
+		"INSERT INTO raw_content_repository VALUES (11, 1), (13, 3)",
+		"INSERT INTO raw_asset VALUES (101, 11, '/a.txt', 1001), (103, 13, '/c.txt', 1003)",
+		"INSERT INTO raw_asset_blob VALUES (1001, 'default@11111111-1111-1111-1111-111111111111'), (1003, 'other@33333333-3333-3333-3333-333333333333')",
+		"INSERT INTO maven2_content_repository VALUES (12, 2)",
+		"INSERT INTO maven2_asset VALUES (102, 12, '/b.jar', 1002)",
+		"INSERT INTO maven2_asset_blob VALUES (1002, 'default@22222222-2222-2222-2222-222222222222@2024-01-02T03:04')")
+	for _, stmt := range stmts {
+		_, err = db.Exec(stmt)
+		assert.NoError(t, err, stmt)
+	}
+	return path
+}
+
+func TestSqliteDialect_Queries_ReturnRows(t *testing.T) {
+	path := createTestSqliteDb(t)
+	assert.True(t, IsSqliteFile(path))
+	assert.False(t, IsSqliteFile(filepath.Join(t.TempDir(), "not_exist.db")))
+	assert.NoError(t, SetDialect(DB_TYPE_SQLITE))
+	defer SetDialect("")
+	db := OpenDb(path)
+	defer db.Close()
+	dialect := GetDialect()
+
+	rows := Query(dialect.RepoFormatQuery("default"), db, 0)
+	repo2Fmt := make(map[string]string)
+	for rows.Next() {
+		var name, format string
+		assert.NoError(t, rows.Scan(&name, &format))
+		repo2Fmt[name] = format
+	}
+	_ = rows.Close()
+	assert.Equal(t, map[string]string{"raw-hosted": "raw", "maven-proxy": "maven2"}, repo2Fmt)
+
+	// Same as the orphaned blob check (LIMIT in each query), and the union of the formats
+	query := dialect.UnionAll([]string{
+		dialect.AssetBlobQuery("raw", "a.path, ab.blob_ref", []string{"raw-hosted", "raw-other"}, "AND blob_ref LIKE '%11111111-1111-1111-1111-111111111111%' LIMIT 1"),
+		dialect.AssetBlobQuery("maven2", "a.path, ab.blob_ref", []string{"maven-proxy"}, "AND blob_ref LIKE '%22222222-2222-2222-2222-222222222222%' LIMIT 1"),
+	})
+	rows = Query(query, db, 0)
+	var results []string
+	for rows.Next() {
+		var repoName, assetPath, blobRef string
+		assert.NoError(t, rows.Scan(&repoName, &assetPath, &blobRef))
+		results = append(results, repoName+"|"+assetPath)
+	}
+	_ = rows.Close()
+	sort.Strings(results)
+	assert.Equal(t, []string{"maven-proxy|/b.jar", "raw-hosted|/a.txt"}, results)
+
+	// The DB is opened as read-only
+	_, err := db.Exec("DELETE FROM raw_asset")
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+// Package lib: errors file (the blobs which still failed after retries) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var errorsFileMu sync.Mutex
+var errorsFilePath = ""
+var errorsFile *os.File
+var ErrorsNum int64 = 0 // Atomic
+
+// SetErrorsFile sets the errors file path. The file is created when the first error is recorded.
+func SetErrorsFile(path string) {
+	errorsFileMu.Lock()
+	defer errorsFileMu.Unlock()
+	errorsFilePath = path
+}
+
+// RecordError appends the path which failed after retries, so that it can be re-run with -rF
+func RecordError(path string, operation string, err error) {
+	atomic.AddInt64(&ErrorsNum, 1)
+	errorsFileMu.Lock()
+	defer errorsFileMu.Unlock()
+	if len(errorsFilePath) == 0 {
+		return
+	}
+	if errorsFile == nil {
+		f, errO := os.OpenFile(errorsFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if errO != nil {
+			h.Log("ERROR", fmt.Sprintf("Opening the errors file %s failed with %s", errorsFilePath, errO.Error()))
+			errorsFilePath = ""
+			return
+		}
+		errorsFile = f
+		_, _ = errorsFile.WriteString("Path" + common.SEP + "Operation" + common.SEP + "Error\n")
+	}
+	// The error message should be one line
+	msg := strings.Join(strings.Fields(err.Error()), " ")
+	if _, errW := errorsFile.WriteString(path + common.SEP + operation + common.SEP + msg + "\n"); errW != nil {
+		h.Log("ERROR", fmt.Sprintf("Writing into the errors file %s failed with %s", errorsFilePath, errW.Error()))
+	}
+}
+
+// CloseErrorsFile closes the errors file and returns the path if any error was recorded
+func CloseErrorsFile() string {
+	errorsFileMu.Lock()
+	defer errorsFileMu.Unlock()
+	if errorsFile == nil {
+		return ""
+	}
+	_ = errorsFile.Close()
+	errorsFile = nil
+	return errorsFilePath
+}
@@ -3,6 +3,7 @@ package lib
 
 import (
 	"FileListV2/common"
+	"bufio"
 	"fmt"
 	"github.com/google/uuid"
 	h "github.com/hajimeo/samples/golang/helpers"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -44,6 +46,23 @@ func GetContainerAndPrefix(uri string) (string, string) {
 	return u.Hostname(), prefix
 }
 
+// SplitGroupMembers splits the comma separated blob store directories/URIs (group blob store members)
+func SplitGroupMembers(baseDirs string) []string {
+	members := make([]string, 0)
+	for _, member := range strings.Split(baseDirs, ",") {
+		member = strings.TrimSpace(member)
+		if len(member) == 0 {
+			continue
+		}
+		if slices.Contains(members, member) {
+			h.Log("WARN", "Duplicate member: "+member+" is ignored")
+			continue
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
 func GetContentPath(blobStoreWithPrefix string, container string) string {
 	// Return the relative path starting from 'content' folder
 	bsType := GetSchema(blobStoreWithPrefix)
@@ -55,7 +74,7 @@ func GetContentPath(blobStoreWithPrefix string, container string) string {
 	// Default (empty) is "file"
 	if bsType == "" || bsType == "file" {
 		return GetUpToContent(blobStoreWithPrefix)
-	} else if bsType == "s3" || bsType == "az" {
+	} else if bsType == "s3" || bsType == "az" || bsType == "gs" {
 		contentPath := GetUpToContent(blobStoreWithPrefix)
 		if len(container) == 0 {
 			return contentPath
@@ -63,7 +82,7 @@ func GetContentPath(blobStoreWithPrefix string, container string) string {
 		//h.Log("DEBUG", "(GetContentPath) contentPath:"+contentPath+", container:"+container)
 		return strings.SplitAfter(contentPath, container+"/")[1]
 	} else {
-		h.Log("TODO", "Do something for Google etc. blob store content path")
+		h.Log("WARN", "Unsupported blob store type: "+bsType+" for content path")
 		return ""
 	}
 }
@@ -298,6 +317,30 @@ func ExtractBlobIdFromString(pathLikeLine string) string {
 	return common.RxBlobId.FindString(pathLikeLine)
 }
 
+// ReadBlobIdsFromFile reads the file line by line and returns the map of blob ID (UUID only) and the line
+func ReadBlobIdsFromFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	blobIds := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // .properties content (-P) can be long
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Not using the created date part (@yyyy-mm-ddThh:mm) because the saved result may use the path or blob_ref
+		blobId := common.RxBlobId.FindString(ExtractBlobIdFromString(line))
+		if len(blobId) == 0 {
+			continue
+		}
+		if _, ok := blobIds[blobId]; !ok {
+			blobIds[blobId] = line
+		}
+	}
+	return blobIds, scanner.Err()
+}
+
 func GetRepoName(contents string) string {
 	if len(contents) > 0 {
 		m := common.RxRepoName.FindStringSubmatch(contents)
@@ -322,6 +365,15 @@ func GetBlobName(contents string) string {
 	return ""
 }
 
+// GetSha1 returns the sha1 recorded in the .properties contents, or empty string if not recorded
+func GetSha1(contents string) string {
+	m := common.RxSha1.FindStringSubmatch(contents)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
+
 func GenBlobPath(blobIdLikeString string, extension string) string {
 	// NOTE: this returns path without slash at the beginning
 	blobId := blobIdLikeString
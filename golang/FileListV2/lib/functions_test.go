@@ -3,6 +3,7 @@ package lib
 import (
 	"FileListV2/common"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -20,6 +21,13 @@ func TestGetSchema_ValidURL_ReturnsSchema(t *testing.T) {
 	assert.Equal(t, "", result)
 }
 
+func TestSplitGroupMembers_CommaSeparated_ReturnsMembers(t *testing.T) {
+	result := SplitGroupMembers("/var/tmp/bs1, s3://s3-test-bucket/s3-test-prefix/,,az://az-container/prefix/,/var/tmp/bs1")
+	assert.Equal(t, []string{"/var/tmp/bs1", "s3://s3-test-bucket/s3-test-prefix/", "az://az-container/prefix/"}, result)
+	result = SplitGroupMembers("/var/tmp/bs1")
+	assert.Equal(t, []string{"/var/tmp/bs1"}, result)
+}
+
 func TestGetContentPath_NoType_ReturnsFullPath(t *testing.T) {
 	common.BsType = ""
 	result := GetContentPath("/base/dir", "")
@@ -520,6 +528,13 @@ func TestGetBlobName_EmptyContent_ReturnsEmptyString(t *testing.T) {
 	assert.Equal(t, "", result)
 }
 
+func TestGetSha1_MultiAndSingleLine_ReturnsSha1(t *testing.T) {
+	assert.Equal(t, "a9993e364706816aba3e25717850c26c9cd0d89d", GetSha1("size=3\nsha1=a9993e364706816aba3e25717850c26c9cd0d89d\n"))
+	assert.Equal(t, "a9993e364706816aba3e25717850c26c9cd0d89d", GetSha1("creationTime=1,sha1=a9993e364706816aba3e25717850c26c9cd0d89d,size=3"))
+	assert.Equal(t, "", GetSha1("@BlobStore.blob-name=/sha1=a9993e364706816aba3e25717850c26c9cd0d89d"))
+	assert.Equal(t, "", GetSha1(""))
+}
+
 func TestGenBlobPath_ValidBlobId_ReturnsCorrectPath(t *testing.T) {
 	result := GenBlobPath("f062f002-88f0-4b53-aeca-7324e9609329", ".properties")
 	expected := "vol-42/chap-31/f062f002-88f0-4b53-aeca-7324e9609329.properties"
@@ -611,3 +626,18 @@ func TestGetBlobRef_WithTab_FileListResult(t *testing.T) {
 	result := GetBlobRef(blobRef, "default")
 	assert.Equal(t, "default@08080d79-06b0-4274-885e-ea78b8c463f5", result)
 }
+
+func TestReadBlobIdsFromFile_MixedLines_ReturnsUniqueBlobIds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blob_ids.tsv")
+	contents := "Path\tLastModified\tSize\n" +
+		"filelist-test/content/vol-08/chap-08/08080d79-06b0-4274-885e-ea78b8c463f5.properties\t2025-01-01\t10\n" +
+		"filelist-test/content/vol-08/chap-08/08080d79-06b0-4274-885e-ea78b8c463f5.bytes\t2025-01-01\t10\n" +
+		"raw-hosted\t/dummies/staging_move2.txt\tdefault@47d9e6d4-308e-4984-89f2-96db825ea66c@2025-12-17T08:15\n"
+	err := os.WriteFile(path, []byte(contents), 0644)
+	assert.NoError(t, err)
+	result, err := ReadBlobIdsFromFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Contains(t, result["08080d79-06b0-4274-885e-ea78b8c463f5"], ".properties")
+	assert.Contains(t, result, "47d9e6d4-308e-4984-89f2-96db825ea66c")
+}
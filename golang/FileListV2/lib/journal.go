@@ -0,0 +1,93 @@
+// Package lib: journal (undo log for -RDel and -wStr, and the record of the files created by -repairProps) related functions.
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const JOURNAL_EXT = ".jsonl"
+
+// JournalEntry is the state of one blob *before* modifying, so that -undo can restore it
+type JournalEntry struct {
+	Time     string                       `json:"time"`
+	Action   string                       `json:"action"`  // 'RDel', 'wStr' or 'repairProps' (Contents is the created contents)
+	BaseDir  string                       `json:"baseDir"` // The blob store (member) of the Path
+	Path     string                       `json:"path"`
+	Contents string                       `json:"contents"`       // The original contents of the Path
+	Tags     map[string]map[string]string `json:"tags,omitempty"` // The original tags per path (eg. S3 tags of .properties and .bytes)
+}
+
+type Journal struct {
+	Path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// DefaultJournalPath returns the journal file path in the current directory with the timestamp
+func DefaultJournalPath() string {
+	return "filelist2_journal_" + time.Now().Format("20060102150405") + JOURNAL_EXT
+}
+
+// OpenJournal opens the journal file for appending (created if not exist)
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{Path: path, file: f}, nil
+}
+
+// Record writes the entry as one JSON line. Should be called before modifying the blob.
+func (j *Journal) Record(entry JournalEntry) error {
+	if len(entry.Time) == 0 {
+		entry.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(jsonBytes, '\n'))
+	return err
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// ReadJournal calls apply for each entry in the file order. Empty lines are ignored.
+func ReadJournal(path string, apply func(JournalEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// The .properties contents is usually small, but just in case
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err = json.Unmarshal([]byte(line), &entry); err != nil {
+			return fmt.Errorf("line %d of %s is not a journal entry: %s", lineNum, path, err.Error())
+		}
+		if err = apply(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
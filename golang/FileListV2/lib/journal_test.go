@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournal_RecordAndRead_ReturnsSameEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal"+JOURNAL_EXT)
+	j, err := OpenJournal(path)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Record(JournalEntry{Action: "RDel", BaseDir: "/tmp/bs/", Path: "/tmp/bs/content/a.properties", Contents: "deleted=true\nsize=3",
+		Tags: map[string]map[string]string{"/tmp/bs/content/a.bytes": {"deleted": "true"}}}))
+	assert.NoError(t, j.Record(JournalEntry{Action: "wStr", BaseDir: "/tmp/bs/", Path: "/tmp/bs/content/b.properties", Contents: "size=4"}))
+	assert.NoError(t, j.Close())
+
+	var entries []JournalEntry
+	err = ReadJournal(path, func(entry JournalEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "RDel", entries[0].Action)
+	assert.Equal(t, "deleted=true\nsize=3", entries[0].Contents)
+	assert.Equal(t, "true", entries[0].Tags["/tmp/bs/content/a.bytes"]["deleted"])
+	assert.NotEmpty(t, entries[0].Time)
+	assert.Equal(t, "/tmp/bs/content/b.properties", entries[1].Path)
+	assert.Nil(t, entries[1].Tags)
+}
+
+func TestReadJournal_InvalidLine_ReturnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal"+JOURNAL_EXT)
+	assert.NoError(t, os.WriteFile(path, []byte("{\"action\":\"RDel\"}\n\nnot json\n"), 0644))
+	err := ReadJournal(path, func(entry JournalEntry) error { return nil })
+	assert.ErrorContains(t, err, "line 3")
+}
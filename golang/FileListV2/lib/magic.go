@@ -0,0 +1,41 @@
+// Package lib: magic number (file signature) related functions, used to skip binary files when checking .bytes contents.
+package lib
+
+import (
+	"bytes"
+)
+
+type magicNumber struct {
+	fileType string
+	offset   int
+	magic    []byte
+}
+
+// Not trying to cover all types. Mainly archives/binaries which are common in the repositories.
+var magicNumbers = []magicNumber{
+	{"zip", 0, []byte("PK\x03\x04")}, // also jar, war, nupkg, whl etc.
+	{"zip", 0, []byte("PK\x05\x06")}, // empty zip
+	{"gzip", 0, []byte{0x1f, 0x8b}},  // also tgz (npm)
+	{"bzip2", 0, []byte("BZh")},
+	{"xz", 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{"7z", 0, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+	{"zstd", 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{"rpm", 0, []byte{0xed, 0xab, 0xee, 0xdb}},
+	{"deb", 0, []byte("!<arch>\ndebian")},
+	{"class", 0, []byte{0xca, 0xfe, 0xba, 0xbe}},
+	{"elf", 0, []byte{0x7f, 'E', 'L', 'F'}},
+	{"png", 0, []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}},
+	{"jpeg", 0, []byte{0xff, 0xd8, 0xff}},
+	{"gif", 0, []byte("GIF8")},
+	{"tar", 257, []byte("ustar")},
+}
+
+// DetectBinaryType returns the type of the binary file from the first bytes, or empty string if not detected
+func DetectBinaryType(head []byte) string {
+	for _, m := range magicNumbers {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.fileType
+		}
+	}
+	return ""
+}
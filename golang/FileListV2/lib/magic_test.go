@@ -0,0 +1,21 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetectBinaryType_KnownMagicNumbers_ReturnsType(t *testing.T) {
+	assert.Equal(t, "zip", DetectBinaryType([]byte("PK\x03\x04\x14\x00\x08\x00")))
+	assert.Equal(t, "gzip", DetectBinaryType([]byte{0x1f, 0x8b, 0x08, 0x00}))
+	assert.Equal(t, "class", DetectBinaryType([]byte{0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00}))
+	tarHead := make([]byte, 512)
+	copy(tarHead[257:], "ustar")
+	assert.Equal(t, "tar", DetectBinaryType(tarHead))
+}
+
+func TestDetectBinaryType_TextOrShort_ReturnsEmpty(t *testing.T) {
+	assert.Equal(t, "", DetectBinaryType([]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<metadata>")))
+	assert.Equal(t, "", DetectBinaryType([]byte("PK")))
+	assert.Equal(t, "", DetectBinaryType(nil))
+}
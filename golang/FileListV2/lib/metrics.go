@@ -0,0 +1,109 @@
+// Package lib: Prometheus metrics (-metricsAddr) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NOTE: the metrics are always updated (cheap), but exposed only when -metricsAddr is given.
+var (
+	metricObjectsChecked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_objects_checked_total",
+		Help: "Number of objects checked (listed from the blob store before filtering)",
+	}, []string{"backend"})
+	metricObjectsListed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_objects_listed_total",
+		Help: "Number of objects printed/saved (after filtering)",
+	}, []string{"backend"})
+	metricOrphanedBlobs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "filelist_orphaned_blobs_total",
+		Help: "Number of orphaned blobs (in the blob store but not in the DB) found with -src BS",
+	})
+	metricDeadBlobs = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "filelist_dead_blobs_total",
+		Help: "Number of dead blobs (in the DB but not in the blob store) found with -src DB",
+	})
+	metricCopyErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_copy_errors_total",
+		Help: "Number of errors/warnings when copying to -bTo, by the error code (eg. ERROR_READ_PROPS)",
+	}, []string{"code"})
+	metricRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "filelist_request_duration_seconds",
+		Help:    "Latency of the blob store API requests (including retries)",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"backend", "operation"})
+	metricRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_request_errors_total",
+		Help: "Number of the blob store API requests which returned an error",
+	}, []string{"backend", "operation"})
+	metricSlowRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_slow_requests_total",
+		Help: "Number of the blob store API requests slower than -slowMS",
+	}, []string{"backend", "operation"})
+	metricThrottled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "filelist_throttled_requests_total",
+		Help: "Number of the blob store API requests which returned the throttling error (eg. 503 SlowDown)",
+	}, []string{"backend"})
+)
+
+// StartMetricsServer starts the HTTP server for /metrics in background, and returns the listening address (eg. for ':0').
+// Returns error if the address can not be listened.
+func StartMetricsServer(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			h.Log("ERROR", fmt.Sprintf("Metrics server on %s stopped with %s", addr, err.Error()))
+		}
+	}()
+	h.Log("INFO", fmt.Sprintf("Prometheus metrics are exposed at http://%s/metrics", listener.Addr().String()))
+	return listener.Addr().String(), nil
+}
+
+func CountChecked(backend string) {
+	metricObjectsChecked.WithLabelValues(backend).Inc()
+}
+
+func CountListed(backend string) {
+	metricObjectsListed.WithLabelValues(backend).Inc()
+}
+
+func CountOrphanedBlob() {
+	metricOrphanedBlobs.Inc()
+}
+
+func CountDeadBlob() {
+	metricDeadBlobs.Inc()
+}
+
+func CountCopyError(code string) {
+	metricCopyErrors.WithLabelValues(code).Inc()
+}
+
+func CountThrottled(backend string) {
+	metricThrottled.WithLabelValues(backend).Inc()
+}
+
+// ObserveRequest records the latency of one API request, which started at startMs
+func ObserveRequest(backend string, operation string, startMs int64, err error) {
+	elapsedMs := time.Now().UnixMilli() - startMs
+	metricRequestSeconds.WithLabelValues(backend, operation).Observe(float64(elapsedMs) / 1000)
+	if err != nil {
+		metricRequestErrors.WithLabelValues(backend, operation).Inc()
+	}
+	if elapsedMs >= common.SlowMS {
+		metricSlowRequests.WithLabelValues(backend, operation).Inc()
+	}
+}
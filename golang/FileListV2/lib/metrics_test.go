@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"FileListV2/common"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserveRequest_SlowAndError_Counted(t *testing.T) {
+	origSlowMS := common.SlowMS
+	common.SlowMS = 100
+	defer func() { common.SlowMS = origSlowMS }()
+
+	ObserveRequest("test", "GetObject", time.Now().UnixMilli(), nil)
+	ObserveRequest("test", "GetObject", time.Now().UnixMilli()-200, errors.New("dummy"))
+	// One histogram per backend and operation
+	assert.Equal(t, 1, testutil.CollectAndCount(metricRequestSeconds, "filelist_request_duration_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metricSlowRequests.WithLabelValues("test", "GetObject")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metricRequestErrors.WithLabelValues("test", "GetObject")))
+}
+
+func TestStartMetricsServer_CopyError_Exposed(t *testing.T) {
+	CountCopyError("ERROR_READ_PROPS")
+	_, err := StartMetricsServer("invalid:address:1")
+	assert.Error(t, err)
+	// Any free port
+	addr, err := StartMetricsServer("127.0.0.1:0")
+	assert.NoError(t, err)
+	resp, err := http.Get("http://" + addr + "/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.True(t, strings.Contains(string(body), `filelist_copy_errors_total{code="ERROR_READ_PROPS"} 1`))
+}
@@ -0,0 +1,162 @@
+// Package lib: output format (tsv, csv, jsonl) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// ParseProps converts the .properties contents to the map, in the same way as java.util.Properties.load().
+// Comment lines are ignored, and the escaped characters (such as '\:', '\=' and '\uXXXX') are unescaped.
+func ParseProps(contents string) map[string]string {
+	props := make(map[string]string)
+	lines := strings.Split(strings.ReplaceAll(contents, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// The line ending with the odd number of '\' continues to the next line
+		for isContinued(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		key, value := splitPropLine(line)
+		props[unescapeProp(key)] = unescapeProp(value)
+	}
+	return props
+}
+
+func isContinued(line string) bool {
+	backslashes := len(line) - len(strings.TrimRight(line, `\`))
+	return backslashes%2 == 1
+}
+
+// splitPropLine splits the line with the first unescaped '=', ':' or white space
+func splitPropLine(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			value := strings.TrimLeft(line[i:], " \t\f")
+			if len(value) > 0 && (value[0] == '=' || value[0] == ':') {
+				value = strings.TrimLeft(value[1:], " \t\f")
+			}
+			return line[:i], value
+		}
+	}
+	return line, ""
+}
+
+func unescapeProp(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var sb strings.Builder
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '\\' || i+1 >= len(runes) {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 't':
+			sb.WriteRune('\t')
+		case 'n':
+			sb.WriteRune('\n')
+		case 'r':
+			sb.WriteRune('\r')
+		case 'f':
+			sb.WriteRune('\f')
+		case 'u':
+			r, ok := parseUnicodeEscape(runes, i+1)
+			if !ok {
+				sb.WriteRune('u')
+				continue
+			}
+			i += 4
+			// The character outside BMP is written as the surrogate pair (\uD83D\uDE00)
+			if utf16.IsSurrogate(r) {
+				if r2, ok2 := parseUnicodeEscape(runes, i+3); ok2 && runes[i+1] == '\\' && runes[i+2] == 'u' {
+					if decoded := utf16.DecodeRune(r, r2); decoded != unicode.ReplacementChar {
+						r = decoded
+						i += 6
+					}
+				}
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+	return sb.String()
+}
+
+// parseUnicodeEscape parses the 4 hex digits from runes[start]
+func parseUnicodeEscape(runes []rune, start int) (rune, bool) {
+	if start+4 > len(runes) {
+		return 0, false
+	}
+	code, err := strconv.ParseUint(string(runes[start:start+4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(code), true
+}
+
+// FormatLine converts the tab separated line to the output format (common.OutputFormat) with the column names
+// For 'jsonl', the Properties column should be the raw .properties contents (multiple lines), as the sorted one line is lossy.
+func FormatLine(line string, cols []string, format string) string {
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write(strings.Split(line, common.SEP))
+		w.Flush()
+		return strings.TrimSuffix(buf.String(), "\n")
+	case "jsonl":
+		record := make(map[string]interface{})
+		for i, value := range strings.Split(line, common.SEP) {
+			key := "Col" + strconv.Itoa(i+1)
+			if i < len(cols) {
+				// "Misc." to "Misc"
+				key = strings.TrimSuffix(cols[i], ".")
+			}
+			switch key {
+			case "Properties":
+				record[key] = ParseProps(value)
+			case "Tags":
+				// Tags is already JSON string
+				if len(value) > 0 && json.Valid([]byte(value)) {
+					record[key] = json.RawMessage(value)
+				} else {
+					record[key] = value
+				}
+			case "Size":
+				if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+					record[key] = size
+				} else {
+					record[key] = value
+				}
+			default:
+				record[key] = value
+			}
+		}
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			return ""
+		}
+		return string(jsonBytes)
+	}
+	return line
+}
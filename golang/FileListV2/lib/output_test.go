@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseProps_RawContents_ReturnsUnescapedMap(t *testing.T) {
+	// The blob name containing ',' and '=' is lossy in the sorted one line
+	contents := "#Tue Aug 12 03:21:00 UTC 2025\n@BlobStore.blob-name=/a,b\\=c\\:d.txt\n@Bucket.repo-name=raw-hosted\n\nsize=11\r\n" +
+		"@BlobStore.created-by=\\u3042\\uD83D\\uDE00\n! another comment\n  deleted = true\ndeletedReason=long \\\n    reason\n"
+	props := ParseProps(contents)
+	assert.Equal(t, map[string]string{
+		"@BlobStore.blob-name":  "/a,b=c:d.txt",
+		"@Bucket.repo-name":     "raw-hosted",
+		"size":                  "11",
+		"@BlobStore.created-by": "あ😀",
+		"deleted":               "true",
+		"deletedReason":         "long reason",
+	}, props)
+	// Same as the contents generated for repairing
+	assert.Equal(t, "/test/a=b:c.txt", ParseProps(GenPropsContents(PropsMeta{BlobName: "/test/a=b:c.txt"}, time.Now()))["@BlobStore.blob-name"])
+}
+
+func TestFormatLine_Formats(t *testing.T) {
+	cols := []string{"Path", "LastModified", "Size", "Properties", "Misc."}
+	line := "vol-01/chap-01/test.properties\t2025-01-01 00:00:00 +0000 UTC\t11\t@Bucket.repo-name=raw,size=11\tBYTES_MISSING"
+	assert.Equal(t, line, FormatLine(line, cols, "tsv"))
+	assert.Equal(t, `vol-01/chap-01/test.properties,2025-01-01 00:00:00 +0000 UTC,11,"@Bucket.repo-name=raw,size=11",BYTES_MISSING`, FormatLine(line, cols, "csv"))
+	// jsonl receives the raw .properties contents
+	line = "vol-01/chap-01/test.properties\t2025-01-01 00:00:00 +0000 UTC\t11\t@Bucket.repo-name=raw\nsize=11\tBYTES_MISSING"
+	assert.JSONEq(t, `{"Path":"vol-01/chap-01/test.properties","LastModified":"2025-01-01 00:00:00 +0000 UTC","Size":11,"Properties":{"@Bucket.repo-name":"raw","size":"11"},"Misc":"BYTES_MISSING"}`, FormatLine(line, cols, "jsonl"))
+	assert.JSONEq(t, `{"Path":"a","Tags":{"deleted":"true"}}`, FormatLine("a\t{\"deleted\":\"true\"}", []string{"Path", "Tags"}, "jsonl"))
+	// No column names
+	assert.JSONEq(t, `{"Col1":"a","Col2":"b"}`, FormatLine("a\tb", nil, "jsonl"))
+}
@@ -0,0 +1,165 @@
+// Package lib: Parquet output (-format parquet) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const PARQUET_EXT = ".parquet"
+
+// PARQUET_ROWS_PER_GROUP is to flush the buffered rows as one row group, so that the memory usage does not grow with the file size
+const PARQUET_ROWS_PER_GROUP = 100000
+
+// The layout of time.Time.String(), which is used for the LastModified column
+const MOD_TIME_LAYOUT = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// ParquetRow is one row of the Parquet output. The columns from the .properties are empty (null) if not a .properties file.
+// NOTE: the zero value of the optional non-pointer column is written as null.
+type ParquetRow struct {
+	Path            string  `parquet:"path"`
+	LastModified    int64   `parquet:"last_modified,timestamp(millisecond),optional"`
+	Size            int64   `parquet:"size"`
+	RepoName        *string `parquet:"repo_name,dict,optional"`
+	BlobName        *string `parquet:"blob_name,optional"`
+	Deleted         bool    `parquet:"deleted"`
+	DeletedDateTime int64   `parquet:"deleted_date_time,timestamp(millisecond),optional"`
+	ContentType     *string `parquet:"content_type,dict,optional"`
+	Properties      *string `parquet:"properties,optional"`
+	Owner           *string `parquet:"owner,dict,optional"`
+	Tags            *string `parquet:"tags,optional"`
+	Misc            *string `parquet:"misc,optional"`
+	Member          *string `parquet:"member,dict,optional"`
+}
+
+type parquetFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *parquet.GenericWriter[ParquetRow]
+	rows   int
+}
+
+var parquetFiles = make(map[string]*parquetFile)
+var parquetFilesMu sync.Mutex
+
+// ToParquetRow converts the tab separated output line to ParquetRow with the column names (same as FormatLine)
+func ToParquetRow(line string, cols []string) ParquetRow {
+	row := ParquetRow{}
+	for i, value := range strings.Split(line, common.SEP) {
+		if i >= len(cols) {
+			break
+		}
+		switch cols[i] {
+		case "Path":
+			row.Path = value
+		case "LastModified":
+			if t, err := time.Parse(MOD_TIME_LAYOUT, value); err == nil {
+				row.LastModified = t.UnixMilli()
+			}
+		case "Size":
+			row.Size, _ = strconv.ParseInt(value, 10, 64)
+		case "Properties":
+			if len(value) == 0 {
+				continue
+			}
+			// The column value is the raw .properties contents (see FormatLine), but stored as the sorted one line as same as tsv
+			sortedOneLine := SortToSingleLine(value)
+			row.Properties = &sortedOneLine
+			props := ParseProps(value)
+			row.RepoName = nonEmptyOrNil(props["@Bucket.repo-name"])
+			row.BlobName = nonEmptyOrNil(props["@BlobStore.blob-name"])
+			row.ContentType = nonEmptyOrNil(props["@BlobStore.content-type"])
+			row.Deleted = props["deleted"] == "true"
+			row.DeletedDateTime, _ = strconv.ParseInt(props["deletedDateTime"], 10, 64)
+		case "Owner":
+			row.Owner = nonEmptyOrNil(value)
+		case "Tags":
+			row.Tags = nonEmptyOrNil(value)
+		case "Misc.":
+			row.Misc = nonEmptyOrNil(value)
+		case "Member":
+			row.Member = nonEmptyOrNil(value)
+		}
+	}
+	return row
+}
+
+func nonEmptyOrNil(value string) *string {
+	if len(value) == 0 {
+		return nil
+	}
+	return &value
+}
+
+// WriteParquetLine appends the tab separated output line into the Parquet file.
+// As a Parquet file can not be appended after closing, the writer per path is kept open until CloseParquet is called.
+func WriteParquetLine(path string, line string, cols []string) error {
+	parquetFilesMu.Lock()
+	pf, ok := parquetFiles[path]
+	if !ok {
+		// Parquet file can not be appended, so always truncating
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			parquetFilesMu.Unlock()
+			return err
+		}
+		pf = &parquetFile{
+			file:   file,
+			writer: parquet.NewGenericWriter[ParquetRow](file, parquet.Compression(&parquet.Zstd)),
+		}
+		parquetFiles[path] = pf
+	}
+	parquetFilesMu.Unlock()
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if _, err := pf.writer.Write([]ParquetRow{ToParquetRow(line, cols)}); err != nil {
+		return err
+	}
+	pf.rows++
+	if pf.rows%PARQUET_ROWS_PER_GROUP == 0 {
+		return pf.writer.Flush()
+	}
+	return nil
+}
+
+// CloseParquet writes the footer of the Parquet file and closes it. Does nothing if the path is not opened.
+func CloseParquet(path string) error {
+	parquetFilesMu.Lock()
+	pf, ok := parquetFiles[path]
+	delete(parquetFiles, path)
+	parquetFilesMu.Unlock()
+	if !ok {
+		return nil
+	}
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if err := pf.writer.Close(); err != nil {
+		_ = pf.file.Close()
+		return fmt.Errorf("closing parquet writer for %s failed with %s", path, err.Error())
+	}
+	return pf.file.Close()
+}
+
+// CloseAllParquet closes all opened Parquet files, and returns the last error
+func CloseAllParquet() error {
+	parquetFilesMu.Lock()
+	paths := make([]string, 0, len(parquetFiles))
+	for path := range parquetFiles {
+		paths = append(paths, path)
+	}
+	parquetFilesMu.Unlock()
+	var lastErr error
+	for _, path := range paths {
+		if err := CloseParquet(path); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToParquetRow_ListingLine_ReturnsTypedRow(t *testing.T) {
+	cols := []string{"Path", "LastModified", "Size", "Properties", "Misc.", "Member"}
+	line := "vol-01/chap-01/test.properties\t2025-01-01 00:00:01.5 +0000 UTC\t11\t@BlobStore.blob-name=/a,b\\=c.txt\n@BlobStore.content-type=text/plain\n@Bucket.repo-name=raw\ndeleted=true\ndeletedDateTime=1735689600000\tBYTES_MISSING\t/tmp/bs1"
+	row := ToParquetRow(line, cols)
+	assert.Equal(t, "vol-01/chap-01/test.properties", row.Path)
+	assert.Equal(t, int64(1735689601500), row.LastModified)
+	assert.Equal(t, int64(11), row.Size)
+	assert.Equal(t, "raw", *row.RepoName)
+	assert.Equal(t, "/a,b=c.txt", *row.BlobName)
+	// The properties column is stored as the sorted one line
+	assert.Equal(t, `@BlobStore.blob-name=/a,b\=c.txt,@BlobStore.content-type=text/plain,@Bucket.repo-name=raw,deleted=true,deletedDateTime=1735689600000`, *row.Properties)
+	assert.Equal(t, "text/plain", *row.ContentType)
+	assert.True(t, row.Deleted)
+	assert.Equal(t, int64(1735689600000), row.DeletedDateTime)
+	assert.Equal(t, "BYTES_MISSING", *row.Misc)
+	assert.Equal(t, "/tmp/bs1", *row.Member)
+	assert.Nil(t, row.Owner)
+
+	// .bytes file does not have the properties
+	row = ToParquetRow("vol-01/chap-01/test.bytes\t2025-01-01 00:00:00 +0000 UTC\t3\t", cols[:4])
+	assert.Equal(t, int64(3), row.Size)
+	assert.Nil(t, row.RepoName)
+	assert.False(t, row.Deleted)
+}
+
+func TestWriteParquetLine_WriteAndClose_ReadableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test"+PARQUET_EXT)
+	cols := []string{"Path", "LastModified", "Size", "Properties"}
+	assert.NoError(t, WriteParquetLine(path, "a.properties\t2025-01-01 00:00:00 +0000 UTC\t11\t@Bucket.repo-name=raw\nsize=11", cols))
+	assert.NoError(t, WriteParquetLine(path, "a.bytes\t2025-01-01 00:00:00 +0000 UTC\t11\t", cols))
+	assert.NoError(t, CloseAllParquet())
+	// Closing again does nothing
+	assert.NoError(t, CloseParquet(path))
+
+	rows, err := parquet.ReadFile[ParquetRow](path)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "raw", *rows[0].RepoName)
+	assert.Nil(t, rows[1].RepoName)
+	assert.Equal(t, int64(0), rows[1].DeletedDateTime)
+
+	// Writing again truncates the file
+	assert.NoError(t, WriteParquetLine(path, "b.bytes\t2025-01-01 00:00:00 +0000 UTC\t1\t", cols))
+	assert.NoError(t, CloseParquet(path))
+	rows, err = parquet.ReadFile[ParquetRow](path)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,62 @@
+// Package lib: progress reporting (-progress and SIGUSR1) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressLine returns the snapshot of the counters with the throughput and ETA (estimated from the completed sub directories)
+func ProgressLine(startMs int64) string {
+	dirsTotal := atomic.LoadInt64(&common.DirsTotal)
+	dirsDone := atomic.LoadInt64(&common.DirsDone)
+	checked := atomic.LoadInt64(&common.CheckedNum)
+	elapsed := time.Duration(time.Now().UnixMilli()-startMs) * time.Millisecond
+	perSec := 0.0
+	if elapsed > 0 {
+		perSec = float64(checked) / elapsed.Seconds()
+	}
+	eta := "unknown"
+	if dirsDone > 0 && dirsTotal >= dirsDone {
+		eta = (elapsed * time.Duration(dirsTotal-dirsDone) / time.Duration(dirsDone)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("Progress: dirs: %d/%d, checked: %d, printed: %d, size: %d bytes, %.1f objects/sec, elapsed: %s, ETA: %s",
+		dirsDone, dirsTotal, checked, atomic.LoadInt64(&common.PrintedNum), atomic.LoadInt64(&common.TotalSize), perSec, elapsed.Round(time.Second), eta)
+}
+
+// StartProgress logs ProgressLine every interval (if > 0) and when SIGUSR1 is received, until the returned stop function is called
+func StartProgress(startMs int64, interval time.Duration) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	NotifyProgressSignal(sigCh)
+	var ticker *time.Ticker
+	var tickCh <-chan time.Time
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		tickCh = ticker.C
+	}
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigCh:
+				h.Log("INFO", ProgressLine(startMs))
+			case <-tickCh:
+				h.Log("INFO", ProgressLine(startMs))
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		if ticker != nil {
+			ticker.Stop()
+		}
+		close(done)
+	}
+}
@@ -0,0 +1,20 @@
+package lib
+
+import (
+	"FileListV2/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProgressLine_HalfDirsDone_ReturnsEta(t *testing.T) {
+	common.DirsTotal, common.DirsDone, common.CheckedNum, common.PrintedNum, common.TotalSize = 10, 5, 100, 50, 1024
+	defer func() {
+		common.DirsTotal, common.DirsDone, common.CheckedNum, common.PrintedNum, common.TotalSize = 0, 0, 0, 0, 0
+	}()
+	line := ProgressLine(time.Now().UnixMilli() - 10000)
+	assert.Contains(t, line, "dirs: 5/10, checked: 100, printed: 50, size: 1024 bytes, 10.0 objects/sec, elapsed: 10s, ETA: 10s")
+
+	common.DirsDone = 0
+	assert.Contains(t, ProgressLine(time.Now().UnixMilli()), "ETA: unknown")
+}
@@ -0,0 +1,142 @@
+// Package lib: request and bandwidth limiting (-rps, -bps) related functions.
+package lib
+
+import (
+	"context"
+	"fmt"
+	h "github.com/hajimeo/samples/golang/helpers"
+	"golang.org/x/time/rate"
+	"io"
+	"sync"
+	"time"
+)
+
+const MIN_BACKOFF = 500 * time.Millisecond
+const MAX_BACKOFF = 30 * time.Second
+
+// RPS_RECOVER_INTERVAL is the minimum interval to increase the reduced -rps, so that the recovery does not depend on the number of goroutines
+const RPS_RECOVER_INTERVAL = time.Second
+
+// NOTE: shared by all goroutines and all clients (source and destination). nil means unlimited.
+var requestLimiter *rate.Limiter
+var byteLimiter *rate.Limiter
+var maxRps float64
+var backoffMu sync.Mutex
+var backoffUntil time.Time
+var backoffDur time.Duration
+var rpsRecoveredAt time.Time
+
+// InitRateLimits sets the global limits of the requests/second and the bytes/second (0 = unlimited)
+func InitRateLimits(rps float64, bps int64) {
+	maxRps = rps
+	requestLimiter = nil
+	byteLimiter = nil
+	if rps > 0 {
+		requestLimiter = rate.NewLimiter(rate.Limit(rps), max(1, int(rps)))
+	}
+	if bps > 0 {
+		// Allowing 1 second burst, which is also the max size of one Read
+		byteLimiter = rate.NewLimiter(rate.Limit(bps), int(bps))
+	}
+	backoffMu.Lock()
+	backoffUntil = time.Time{}
+	backoffDur = 0
+	rpsRecoveredAt = time.Time{}
+	backoffMu.Unlock()
+}
+
+// WaitRequest blocks until one request is allowed, including the back-off after the throttling error
+func WaitRequest() {
+	backoffMu.Lock()
+	wait := time.Until(backoffUntil)
+	backoffMu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if requestLimiter != nil {
+		_ = requestLimiter.Wait(context.Background())
+	}
+}
+
+// WaitBytes blocks until n bytes are allowed
+func WaitBytes(n int) {
+	if byteLimiter == nil {
+		return
+	}
+	burst := byteLimiter.Burst()
+	for n > 0 {
+		chunk := min(n, burst)
+		_ = byteLimiter.WaitN(context.Background(), chunk)
+		n -= chunk
+	}
+}
+
+type limitedReadCloser struct {
+	io.ReadCloser
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if burst := byteLimiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	WaitBytes(n)
+	return n, err
+}
+
+// LimitReadCloser returns the reader which is limited by -bps, or the same reader if -bps is not given
+func LimitReadCloser(reader io.ReadCloser) io.ReadCloser {
+	if byteLimiter == nil || reader == nil {
+		return reader
+	}
+	return &limitedReadCloser{reader}
+}
+
+// Throttled should be called when the backend returns the throttling error (eg. S3 503 SlowDown).
+// All requests are paused with the exponential back-off, and the current -rps is halved (restored gradually by Succeeded).
+// The concurrent requests sent before the pause may also be throttled, so only one back-off step is applied per back-off window.
+func Throttled(backend string) {
+	CountThrottled(backend)
+	backoffMu.Lock()
+	defer backoffMu.Unlock()
+	now := time.Now()
+	if now.Before(backoffUntil) {
+		return
+	}
+	backoffDur = min(max(backoffDur*2, MIN_BACKOFF), MAX_BACKOFF)
+	backoffUntil = now.Add(backoffDur)
+	// Not restoring the -rps until this back-off window ends
+	rpsRecoveredAt = backoffUntil
+	msg := fmt.Sprintf("Throttled by %s. Pausing requests for %s", backend, backoffDur)
+	if requestLimiter != nil {
+		newLimit := max(requestLimiter.Limit()/2, rate.Limit(min(1, maxRps)))
+		requestLimiter.SetLimit(newLimit)
+		msg += fmt.Sprintf(" and reducing to %.1f requests/sec", float64(newLimit))
+	}
+	h.Log("WARN", msg)
+}
+
+// Succeeded should be called when the request succeeded. Resets the back-off and increases the reduced -rps by 5% of -rps per RPS_RECOVER_INTERVAL.
+func Succeeded() {
+	backoffMu.Lock()
+	defer backoffMu.Unlock()
+	now := time.Now()
+	if now.Before(backoffUntil) {
+		// The request sent before the pause, so not the sign of the recovery
+		return
+	}
+	backoffDur = 0
+	if requestLimiter == nil || requestLimiter.Limit() >= rate.Limit(maxRps) || now.Sub(rpsRecoveredAt) < RPS_RECOVER_INTERVAL {
+		return
+	}
+	requestLimiter.SetLimit(min(requestLimiter.Limit()+rate.Limit(maxRps/20), rate.Limit(maxRps)))
+	rpsRecoveredAt = now
+}
+
+// CurrentRps returns the current requests/second limit (0 = unlimited)
+func CurrentRps() float64 {
+	if requestLimiter == nil {
+		return 0
+	}
+	return float64(requestLimiter.Limit())
+}
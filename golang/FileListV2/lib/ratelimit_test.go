@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// endBackoffWindow moves the back-off window and the last -rps increase to the past, instead of sleeping
+func endBackoffWindow() {
+	backoffMu.Lock()
+	backoffUntil = time.Now().Add(-time.Millisecond)
+	rpsRecoveredAt = time.Now().Add(-RPS_RECOVER_INTERVAL)
+	backoffMu.Unlock()
+}
+
+func TestThrottled_WithRps_HalvesAndSucceededRestores(t *testing.T) {
+	InitRateLimits(100, 0)
+	defer InitRateLimits(0, 0)
+	Throttled("s3")
+	assert.Equal(t, 50.0, CurrentRps())
+	endBackoffWindow()
+	Throttled("s3")
+	assert.Equal(t, 25.0, CurrentRps())
+	for i := 0; i < 20; i++ {
+		endBackoffWindow()
+		Succeeded()
+	}
+	assert.Equal(t, 100.0, CurrentRps())
+}
+
+func TestThrottled_SameBackoffWindow_OneStepOnly(t *testing.T) {
+	InitRateLimits(100, 0)
+	defer InitRateLimits(0, 0)
+	// Such as the concurrent requests sent before the pause
+	for i := 0; i < 10; i++ {
+		Throttled("s3")
+	}
+	assert.Equal(t, 50.0, CurrentRps())
+	assert.Equal(t, MIN_BACKOFF, backoffDur)
+	// The success during the back-off window does not reset the back-off
+	Succeeded()
+	assert.Equal(t, MIN_BACKOFF, backoffDur)
+	assert.Equal(t, 50.0, CurrentRps())
+}
+
+func TestSucceeded_ManySuccesses_IncreasesOncePerInterval(t *testing.T) {
+	InitRateLimits(100, 0)
+	defer InitRateLimits(0, 0)
+	Throttled("s3")
+	endBackoffWindow()
+	for i := 0; i < 100; i++ {
+		Succeeded()
+	}
+	assert.Equal(t, 55.0, CurrentRps())
+	endBackoffWindow()
+	Succeeded()
+	assert.Equal(t, 60.0, CurrentRps())
+}
+
+func TestWaitRequest_AfterThrottled_WaitsBackoff(t *testing.T) {
+	InitRateLimits(0, 0)
+	defer InitRateLimits(0, 0)
+	Throttled("az")
+	start := time.Now()
+	WaitRequest()
+	assert.GreaterOrEqual(t, time.Since(start), MIN_BACKOFF-50*time.Millisecond)
+	assert.Equal(t, 0.0, CurrentRps())
+}
+
+func TestLimitReadCloser_WithBps_LimitsThroughput(t *testing.T) {
+	InitRateLimits(0, 1000)
+	defer InitRateLimits(0, 0)
+	start := time.Now()
+	// 1000 bytes are allowed as the burst, so the next 500 bytes need about 0.5 second
+	data, err := io.ReadAll(LimitReadCloser(io.NopCloser(strings.NewReader(strings.Repeat("a", 1500)))))
+	assert.NoError(t, err)
+	assert.Len(t, data, 1500)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestLimitReadCloser_NoBps_ReturnsSameReader(t *testing.T) {
+	InitRateLimits(0, 0)
+	reader := io.NopCloser(strings.NewReader("a"))
+	assert.Equal(t, reader, LimitReadCloser(reader))
+}
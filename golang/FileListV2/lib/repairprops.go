@@ -0,0 +1,106 @@
+// Package lib: repair (regenerating the missing .properties from the DB) related functions.
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PropsMeta is the metadata of one asset blob from the DB, to regenerate the .properties file
+type PropsMeta struct {
+	RepoName    string
+	BlobName    string // {format}_asset.path
+	Size        int64
+	ContentType string
+	Created     time.Time
+	CreatedBy   string
+	CreatedByIp string
+	Sha1        string
+}
+
+// GenPropsContents returns the .properties contents in the same format as Nexus writes (java.util.Properties)
+func GenPropsContents(meta PropsMeta, now time.Time) string {
+	lines := []string{"#" + now.UTC().Format("Mon Jan 02 15:04:05 MST 2006")}
+	if len(meta.CreatedBy) > 0 {
+		lines = append(lines, "@BlobStore.created-by="+escapePropValue(meta.CreatedBy))
+	}
+	lines = append(lines, "size="+strconv.FormatInt(meta.Size, 10))
+	lines = append(lines, "@Bucket.repo-name="+escapePropValue(meta.RepoName))
+	lines = append(lines, "creationTime="+strconv.FormatInt(meta.Created.UnixMilli(), 10))
+	if len(meta.CreatedByIp) > 0 {
+		lines = append(lines, "@BlobStore.created-by-ip="+escapePropValue(meta.CreatedByIp))
+	}
+	if len(meta.ContentType) > 0 {
+		lines = append(lines, "@BlobStore.content-type="+escapePropValue(meta.ContentType))
+	}
+	lines = append(lines, "@BlobStore.blob-name="+escapePropValue(meta.BlobName))
+	lines = append(lines, "sha1="+meta.Sha1)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// escapePropValue escapes the value like java.util.Properties.store()
+func escapePropValue(value string) string {
+	var sb strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '\\' || r == '=' || r == ':' || r == '#' || r == '!':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == ' ' && i == 0:
+			sb.WriteString(`\ `)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16Units(r) {
+				sb.WriteString(fmt.Sprintf(`\u%04X`, u))
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func utf16Units(r rune) []rune {
+	if r < 0x10000 {
+		return []rune{r}
+	}
+	r -= 0x10000
+	return []rune{0xD800 + (r>>10)&0x3FF, 0xDC00 + r&0x3FF}
+}
+
+// GetSha1FromAttributes returns checksum.sha1 in the {format}_asset.attributes JSON
+func GetSha1FromAttributes(attributes string) string {
+	var attrs struct {
+		Checksum map[string]string `json:"checksum"`
+	}
+	if err := json.Unmarshal([]byte(attributes), &attrs); err != nil {
+		return ""
+	}
+	return attrs.Checksum["sha1"]
+}
+
+// ParseDbTime converts the timestamp column value, which is time.Time (PostgreSQL) or the text (SQLite, H2 through the PG server mode)
+func ParseDbTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		return ParseDbTime(string(v))
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unsupported timestamp format: %s", v)
+	}
+	return time.Time{}, fmt.Errorf("unsupported timestamp type: %T", value)
+}
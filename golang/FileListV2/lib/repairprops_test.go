@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenPropsContents_ReturnsNexusProperties(t *testing.T) {
+	created, err := ParseDbTime("2024-01-02 03:04:05.123+00")
+	assert.NoError(t, err)
+	meta := PropsMeta{
+		RepoName:    "raw-hosted",
+		BlobName:    "/test/a=b:c.txt",
+		Size:        123,
+		ContentType: "text/plain",
+		Created:     created,
+		CreatedBy:   "admin",
+		Sha1:        GetSha1FromAttributes(`{"checksum": {"md5": "x", "sha1": "da39a3ee5e6b4b0d3255bfef95601890afd80709"}}`),
+	}
+	contents := GenPropsContents(meta, time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC))
+	assert.Equal(t, "#Mon Feb 03 04:05:06 UTC 2025\n"+
+		"@BlobStore.created-by=admin\n"+
+		"size=123\n"+
+		"@Bucket.repo-name=raw-hosted\n"+
+		"creationTime=1704164645123\n"+
+		"@BlobStore.content-type=text/plain\n"+
+		`@BlobStore.blob-name=/test/a\=b\:c.txt`+"\n"+
+		"sha1=da39a3ee5e6b4b0d3255bfef95601890afd80709\n", contents)
+	// The blob name is comparable after removing '\' (same as the orphaned blob check)
+	assert.Equal(t, "/test/a=b:c.txt", strings.ReplaceAll(GetBlobName(SortToSingleLine(contents)), `\`, ""))
+	assert.Equal(t, "raw-hosted", GetRepoName(SortToSingleLine(contents)))
+}
+
+func TestEscapePropValue_NonAscii_ReturnsUnicodeEscape(t *testing.T) {
+	assert.Equal(t, `/\u3042\:x`, escapePropValue("/あ:x"))
+	assert.Equal(t, `\uD83D\uDE00`, escapePropValue("😀"))
+	assert.Equal(t, `\ a`, escapePropValue(" a"))
+}
+
+func TestParseDbTime_UnsupportedValue_ReturnsError(t *testing.T) {
+	_, err := ParseDbTime(123)
+	assert.Error(t, err)
+	_, err = ParseDbTime("yesterday")
+	assert.Error(t, err)
+	parsed, err := ParseDbTime([]byte("2024-01-02T03:04:05Z"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1704164645000), parsed.UnixMilli())
+	assert.Empty(t, GetSha1FromAttributes("not json"))
+}
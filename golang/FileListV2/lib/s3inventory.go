@@ -0,0 +1,237 @@
+// Package lib: S3 Inventory report (-s3Inv) related functions.
+package lib
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	"github.com/scritchley/orc"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InventoryManifest is the manifest.json of the S3 Inventory report
+// @see: https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory-location.html
+type InventoryManifest struct {
+	SourceBucket      string          `json:"sourceBucket"`
+	DestinationBucket string          `json:"destinationBucket"` // eg. 'arn:aws:s3:::inventory-bucket'
+	FileFormat        string          `json:"fileFormat"`        // 'CSV', 'ORC' or 'Parquet'
+	FileSchema        string          `json:"fileSchema"`        // For CSV, the column names (eg. 'Bucket, Key, Size, LastModifiedDate')
+	Files             []InventoryFile `json:"files"`
+}
+
+type InventoryFile struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// InventoryRow is one object in the inventory. Size and LastModified are zero if not included in the inventory.
+type InventoryRow struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// inventoryParquetRow is the subset of the Parquet inventory columns. The missing columns are null.
+type inventoryParquetRow struct {
+	Key              string `parquet:"key"`
+	Size             *int64 `parquet:"size"`
+	LastModifiedDate int64  `parquet:"last_modified_date,timestamp(millisecond),optional"`
+	IsLatest         *bool  `parquet:"is_latest"`
+	IsDeleteMarker   *bool  `parquet:"is_delete_marker"`
+}
+
+// ParseInventoryManifest parses the manifest.json. Returns error if the format is not supported.
+func ParseInventoryManifest(data []byte) (*InventoryManifest, error) {
+	var m InventoryManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Files) == 0 {
+		return nil, fmt.Errorf("no files in the inventory manifest")
+	}
+	if !strings.EqualFold(m.FileFormat, "CSV") && !m.IsParquet() && !m.IsOrc() {
+		return nil, fmt.Errorf("inventory format '%s' is not supported (CSV, ORC or Parquet only)", m.FileFormat)
+	}
+	return &m, nil
+}
+
+// DestinationBucketName returns the bucket name of the data files (without 'arn:aws:s3:::')
+func (m *InventoryManifest) DestinationBucketName() string {
+	return m.DestinationBucket[strings.LastIndex(m.DestinationBucket, ":")+1:]
+}
+
+// IsParquet returns true if the data files are Parquet (otherwise gzipped CSV)
+func (m *InventoryManifest) IsParquet() bool {
+	return strings.EqualFold(m.FileFormat, "Parquet")
+}
+
+// IsOrc returns true if the data files are ORC
+func (m *InventoryManifest) IsOrc() bool {
+	return strings.EqualFold(m.FileFormat, "ORC")
+}
+
+// ReadInventoryCsv calls apply for each current (not deleted) object in the CSV (gzipped or not) with the fileSchema columns
+func ReadInventoryCsv(reader io.Reader, fileSchema string, apply func(InventoryRow) error) error {
+	colIdx := make(map[string]int)
+	for i, col := range strings.Split(fileSchema, ",") {
+		colIdx[strings.TrimSpace(col)] = i
+	}
+	keyIdx, ok := colIdx["Key"]
+	if !ok {
+		return fmt.Errorf("no Key column in the fileSchema: %s", fileSchema)
+	}
+	bufReader := bufio.NewReader(reader)
+	if magic, _ := bufReader.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			return err
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	} else {
+		reader = bufReader
+	}
+	value := func(record []string, col string) string {
+		if i, ok := colIdx[col]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if keyIdx >= len(record) || value(record, "IsDeleteMarker") == "true" || value(record, "IsLatest") == "false" {
+			continue
+		}
+		// The keys in the CSV are URL-encoded
+		key, err := url.QueryUnescape(record[keyIdx])
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %s", record[keyIdx], err.Error())
+		}
+		row := InventoryRow{Key: key}
+		if size := value(record, "Size"); len(size) > 0 {
+			row.Size, _ = strconv.ParseInt(size, 10, 64)
+		}
+		if modTime := value(record, "LastModifiedDate"); len(modTime) > 0 {
+			row.LastModified, _ = time.Parse(time.RFC3339, modTime)
+		}
+		if err = apply(row); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadInventoryParquet calls apply for each current (not deleted) object in the Parquet file
+func ReadInventoryParquet(file *os.File, apply func(InventoryRow) error) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return err
+	}
+	reader := parquet.NewGenericReader[inventoryParquetRow](pf)
+	defer reader.Close()
+	rows := make([]inventoryParquetRow, 1000)
+	for {
+		n, errR := reader.Read(rows)
+		for _, r := range rows[:n] {
+			if (r.IsDeleteMarker != nil && *r.IsDeleteMarker) || (r.IsLatest != nil && !*r.IsLatest) {
+				continue
+			}
+			row := InventoryRow{Key: r.Key}
+			if r.Size != nil {
+				row.Size = *r.Size
+			}
+			if r.LastModifiedDate > 0 {
+				row.LastModified = time.UnixMilli(r.LastModifiedDate).UTC()
+			}
+			if err = apply(row); err != nil {
+				return err
+			}
+		}
+		if errR == io.EOF {
+			return nil
+		}
+		if errR != nil {
+			return errR
+		}
+	}
+}
+
+// inventoryOrcColumns are the ORC inventory columns to read. Only "key" is mandatory.
+var inventoryOrcColumns = []string{"key", "size", "last_modified_date", "is_latest", "is_delete_marker"}
+
+// ReadInventoryOrc calls apply for each current (not deleted) object in the ORC file
+func ReadInventoryOrc(file *os.File, apply func(InventoryRow) error) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	reader, err := orc.NewReader(io.NewSectionReader(file, 0, info.Size()))
+	if err != nil {
+		return err
+	}
+	// Selecting only the columns in the file, as the optional fields may not be included in the inventory
+	colIdx := make(map[string]int)
+	var cols []string
+	for _, col := range reader.Schema().Columns() {
+		if slices.Contains(inventoryOrcColumns, col) {
+			colIdx[col] = len(cols)
+			cols = append(cols, col)
+		}
+	}
+	if _, ok := colIdx["key"]; !ok {
+		return fmt.Errorf("no key column in the ORC schema: %s", reader.Schema().String())
+	}
+	value := func(values []interface{}, col string) interface{} {
+		if i, ok := colIdx[col]; ok && i < len(values) {
+			return values[i]
+		}
+		return nil
+	}
+
+	cursor := reader.Select(cols...)
+	for cursor.Stripes() {
+		for cursor.Next() {
+			values := cursor.Row()
+			if isDeleteMarker, _ := value(values, "is_delete_marker").(bool); isDeleteMarker {
+				continue
+			}
+			if isLatest, ok := value(values, "is_latest").(bool); ok && !isLatest {
+				continue
+			}
+			key, _ := value(values, "key").(string)
+			row := InventoryRow{Key: key}
+			if size, ok := value(values, "size").(int64); ok {
+				row.Size = size
+			}
+			if modTime, ok := value(values, "last_modified_date").(time.Time); ok {
+				row.LastModified = modTime.UTC()
+			}
+			if err = apply(row); err != nil {
+				return err
+			}
+		}
+	}
+	return cursor.Err()
+}
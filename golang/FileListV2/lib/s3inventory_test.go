@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/parquet-go/parquet-go"
+	"github.com/scritchley/orc"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInventoryManifest_Formats_ReturnsManifest(t *testing.T) {
+	_, err := ParseInventoryManifest([]byte(`{"sourceBucket":"src","destinationBucket":"arn:aws:s3:::inv","fileFormat":"JSON","files":[{"key":"a.json"}]}`))
+	assert.ErrorContains(t, err, "not supported")
+
+	m, err := ParseInventoryManifest([]byte(`{"sourceBucket":"src","destinationBucket":"arn:aws:s3:::inv","fileFormat":"ORC","files":[{"key":"a.orc"}]}`))
+	assert.NoError(t, err)
+	assert.True(t, m.IsOrc())
+	assert.False(t, m.IsParquet())
+
+	m, err = ParseInventoryManifest([]byte(`{"sourceBucket":"src","destinationBucket":"arn:aws:s3:::inv","fileFormat":"CSV","fileSchema":"Bucket, Key","files":[{"key":"inv/src/cfg/data/a.csv.gz","size":10}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "inv", m.DestinationBucketName())
+	assert.False(t, m.IsParquet())
+	assert.Equal(t, "inv/src/cfg/data/a.csv.gz", m.Files[0].Key)
+}
+
+func TestReadInventoryCsv_GzippedWithVersions_SkipsOldAndDeleted(t *testing.T) {
+	csvText := `"src","prefix/content/vol-01/chap-01/11111111-1111-1111-1111-111111111111.properties","v1","true","false","92","2024-01-02T03:04:05.000Z"
+"src","prefix/content/vol-01/chap-01/old.properties","v0","false","false","10","2024-01-01T00:00:00.000Z"
+"src","prefix/content/vol-01/chap-01/deleted.properties","v2","true","true","","2024-01-01T00:00:00.000Z"
+"src","prefix/content/with+space%2Bplus.bytes","v1","true","false","3","2024-01-02T03:04:05.000Z"
+`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(csvText))
+	_ = w.Close()
+
+	var rows []InventoryRow
+	err := ReadInventoryCsv(&gz, "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate", func(row InventoryRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "prefix/content/vol-01/chap-01/11111111-1111-1111-1111-111111111111.properties", rows[0].Key)
+	assert.Equal(t, int64(92), rows[0].Size)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), rows[0].LastModified)
+	assert.Equal(t, "prefix/content/with space+plus.bytes", rows[1].Key)
+}
+
+func TestReadInventoryCsv_NoKeyColumn_ReturnsError(t *testing.T) {
+	err := ReadInventoryCsv(strings.NewReader(""), "Bucket, Size", func(row InventoryRow) error { return nil })
+	assert.Error(t, err)
+}
+
+// Same column names as the Parquet S3 Inventory
+type awsInventoryRow struct {
+	Bucket           string `parquet:"bucket"`
+	Key              string `parquet:"key"`
+	VersionId        string `parquet:"version_id,optional"`
+	IsLatest         bool   `parquet:"is_latest"`
+	IsDeleteMarker   bool   `parquet:"is_delete_marker"`
+	Size             int64  `parquet:"size,optional"`
+	LastModifiedDate int64  `parquet:"last_modified_date,timestamp(millisecond),optional"`
+	StorageClass     string `parquet:"storage_class,optional"`
+}
+
+func TestReadInventoryParquet_WithVersions_SkipsOldAndDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.parquet")
+	modMs := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli()
+	err := parquet.WriteFile(path, []awsInventoryRow{
+		{Bucket: "src", Key: "prefix/content/a.properties", IsLatest: true, Size: 92, LastModifiedDate: modMs, StorageClass: "STANDARD"},
+		{Bucket: "src", Key: "prefix/content/old.properties", IsLatest: false, Size: 10, LastModifiedDate: modMs},
+		{Bucket: "src", Key: "prefix/content/deleted.properties", IsLatest: true, IsDeleteMarker: true},
+	})
+	assert.NoError(t, err)
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	var rows []InventoryRow
+	err = ReadInventoryParquet(f, func(row InventoryRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []InventoryRow{{Key: "prefix/content/a.properties", Size: 92, LastModified: time.UnixMilli(modMs).UTC()}}, rows)
+}
+
+func TestReadInventoryParquet_NoVersionColumns_ReturnsAll(t *testing.T) {
+	type row struct {
+		Bucket string `parquet:"bucket"`
+		Key    string `parquet:"key"`
+		Size   int64  `parquet:"size"`
+	}
+	path := filepath.Join(t.TempDir(), "inventory.parquet")
+	assert.NoError(t, parquet.WriteFile(path, []row{{Bucket: "src", Key: "a.properties", Size: 1}, {Bucket: "src", Key: "a.bytes", Size: 2}}))
+	f, _ := os.Open(path)
+	defer f.Close()
+	var keys []string
+	err := ReadInventoryParquet(f, func(row InventoryRow) error {
+		keys = append(keys, row.Key)
+		assert.True(t, row.LastModified.IsZero())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.properties", "a.bytes"}, keys)
+}
+
+func TestReadInventoryOrc_WithVersions_SkipsOldAndDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.orc")
+	f, _ := os.Create(path)
+	// Same column names as the ORC S3 Inventory
+	schema, err := orc.ParseSchema("struct<bucket:string,key:string,version_id:string,is_latest:boolean,is_delete_marker:boolean,size:bigint,last_modified_date:timestamp>")
+	assert.NoError(t, err)
+	w, err := orc.NewWriter(f, orc.SetSchema(schema))
+	assert.NoError(t, err)
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, w.Write("src", "prefix/content/a.properties", "v1", true, false, int64(92), modTime))
+	assert.NoError(t, w.Write("src", "prefix/content/old.properties", "v0", false, false, int64(10), modTime))
+	assert.NoError(t, w.Write("src", "prefix/content/deleted.properties", "v2", true, true, int64(0), modTime))
+	assert.NoError(t, w.Close())
+	_ = f.Close()
+
+	f, _ = os.Open(path)
+	defer f.Close()
+	var rows []InventoryRow
+	err = ReadInventoryOrc(f, func(row InventoryRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, "prefix/content/a.properties", rows[0].Key)
+	assert.Equal(t, int64(92), rows[0].Size)
+	assert.Equal(t, modTime, rows[0].LastModified)
+}
@@ -0,0 +1,127 @@
+// Package lib: summary (-summary) related functions.
+package lib
+
+import (
+	"FileListV2/common"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SummaryStat struct {
+	Count int64 `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+// Summary accumulates the listed objects. The breakdowns are per blob (.properties), and the size is the 'size' in the .properties.
+type Summary struct {
+	mu               sync.Mutex
+	Objects          SummaryStat             `json:"objects"` // All listed files (.properties and .bytes) with the file size
+	Blobs            SummaryStat             `json:"blobs"`
+	Live             SummaryStat             `json:"live"`
+	SoftDeleted      SummaryStat             `json:"softDeleted"`
+	ReclaimableBytes int64                   `json:"reclaimableBytes"` // .bytes and .properties of the soft-deleted blobs, which will be removed by the compaction
+	PerRepo          map[string]*SummaryStat `json:"perRepo"`
+	PerContentType   map[string]*SummaryStat `json:"perContentType"`
+	PerMonth         map[string]*SummaryStat `json:"perMonth"` // LastModified of the .properties in UTC
+}
+
+func NewSummary() *Summary {
+	return &Summary{
+		PerRepo:        make(map[string]*SummaryStat),
+		PerContentType: make(map[string]*SummaryStat),
+		PerMonth:       make(map[string]*SummaryStat),
+	}
+}
+
+// Add accumulates one listed file. sortedProps is the sorted one line .properties contents (empty if not .properties).
+func (s *Summary) Add(path string, modTime time.Time, fileSize int64, sortedProps string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addStat(&s.Objects, fileSize)
+	if !strings.HasSuffix(path, common.PROP_EXT) || len(sortedProps) == 0 {
+		return
+	}
+
+	var blobSize int64
+	if m := common.RxSizeByte.FindStringSubmatch(sortedProps); len(m) > 1 {
+		blobSize, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	addStat(&s.Blobs, blobSize)
+	if common.RxDeleted.MatchString(sortedProps) {
+		addStat(&s.SoftDeleted, blobSize)
+		s.ReclaimableBytes += blobSize + fileSize
+	} else {
+		addStat(&s.Live, blobSize)
+	}
+	repoName := "(unknown)"
+	if m := common.RxRepoName.FindStringSubmatch(sortedProps); len(m) > 2 {
+		repoName = m[2]
+	}
+	addStatToMap(s.PerRepo, repoName, blobSize)
+	contentType := "(unknown)"
+	if m := common.RxContentType.FindStringSubmatch(sortedProps); len(m) > 2 {
+		contentType = m[2]
+	}
+	addStatToMap(s.PerContentType, contentType, blobSize)
+	addStatToMap(s.PerMonth, modTime.UTC().Format("2006-01"), blobSize)
+}
+
+func addStat(stat *SummaryStat, size int64) {
+	stat.Count++
+	stat.Size += size
+}
+
+func addStatToMap(stats map[string]*SummaryStat, key string, size int64) {
+	stat, ok := stats[key]
+	if !ok {
+		stat = &SummaryStat{}
+		stats[key] = stat
+	}
+	addStat(stat, size)
+}
+
+func (s *Summary) Json() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jsonBytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// Text returns the human-readable (tab separated) report
+func (s *Summary) Text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Type%sCount%sSize\n", common.SEP, common.SEP))
+	for _, row := range []struct {
+		name string
+		stat SummaryStat
+	}{{"Objects", s.Objects}, {"Blobs", s.Blobs}, {"Live", s.Live}, {"SoftDeleted", s.SoftDeleted}} {
+		sb.WriteString(fmt.Sprintf("%s%s%d%s%d\n", row.name, common.SEP, row.stat.Count, common.SEP, row.stat.Size))
+	}
+	sb.WriteString(fmt.Sprintf("ReclaimableBytes%s%s%d\n", common.SEP, common.SEP, s.ReclaimableBytes))
+	writeStatsText(&sb, "Repository", s.PerRepo)
+	writeStatsText(&sb, "ContentType", s.PerContentType)
+	writeStatsText(&sb, "Month", s.PerMonth)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func writeStatsText(sb *strings.Builder, title string, stats map[string]*SummaryStat) {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sb.WriteString(fmt.Sprintf("\n%s%sCount%sSize\n", title, common.SEP, common.SEP))
+	for _, key := range keys {
+		sb.WriteString(fmt.Sprintf("%s%s%d%s%d\n", key, common.SEP, stats[key].Count, common.SEP, stats[key].Size))
+	}
+}
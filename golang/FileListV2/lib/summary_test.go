@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryAdd_LiveAndDeleted_AccumulatesBreakdowns(t *testing.T) {
+	s := NewSummary()
+	modTime := time.Date(2025, 8, 12, 3, 21, 0, 0, time.UTC)
+	s.Add("vol-01/chap-01/a.properties", modTime, 100, SortToSingleLine("@BlobStore.blob-name=/a.txt\n@BlobStore.content-type=text/plain\n@Bucket.repo-name=raw-hosted\nsize=11"))
+	s.Add("vol-01/chap-01/a.bytes", modTime, 11, "")
+	s.Add("vol-01/chap-01/b.properties", modTime.AddDate(0, 1, 0), 120, SortToSingleLine("@BlobStore.blob-name=/b.jar\n@Bucket.repo-name=maven-releases\nsize=1000\ndeleted=true\ndeletedDateTime=1757000000000"))
+
+	assert.Equal(t, SummaryStat{Count: 3, Size: 231}, s.Objects)
+	assert.Equal(t, SummaryStat{Count: 2, Size: 1011}, s.Blobs)
+	assert.Equal(t, SummaryStat{Count: 1, Size: 11}, s.Live)
+	assert.Equal(t, SummaryStat{Count: 1, Size: 1000}, s.SoftDeleted)
+	// .bytes size (from the .properties) + .properties file size
+	assert.Equal(t, int64(1120), s.ReclaimableBytes)
+	assert.Equal(t, SummaryStat{Count: 1, Size: 1000}, *s.PerRepo["maven-releases"])
+	assert.Equal(t, SummaryStat{Count: 1, Size: 11}, *s.PerContentType["text/plain"])
+	assert.Equal(t, SummaryStat{Count: 1, Size: 1000}, *s.PerContentType["(unknown)"])
+	assert.Equal(t, SummaryStat{Count: 1, Size: 11}, *s.PerMonth["2025-08"])
+	assert.Equal(t, SummaryStat{Count: 1, Size: 1000}, *s.PerMonth["2025-09"])
+
+	report, err := s.Json()
+	assert.NoError(t, err)
+	assert.Contains(t, report, `"reclaimableBytes": 1120`)
+	assert.True(t, strings.HasPrefix(s.Text(), "Type\tCount\tSize\nObjects\t3\t231\n"))
+	assert.Contains(t, s.Text(), "\nRepository\tCount\tSize\nmaven-releases\t1\t1000\nraw-hosted\t1\t11\n")
+}
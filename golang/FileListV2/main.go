@@ -26,6 +26,12 @@ import (
 
 var Client bs_clients.Client
 var Client2 bs_clients.Client
+var Checkpoint *lib.Checkpoint
+var Summary *lib.Summary
+var Journal *lib.Journal
+var BlobIdMapping *lib.BlobIdMapping
+var BlobRefs *lib.BlobRefSet
+var IntegrityGuard chan struct{}
 
 func usage() {
 	fmt.Println(`
@@ -36,76 +42,141 @@ HOW TO and USAGE EXAMPLES:
 	fmt.Println("")
 }
 
+// applyConfigProfile sets the flags from the config file, only if the flag is not specified in the command line
+func applyConfigProfile() {
+	if len(common.ConfigFile) == 0 {
+		return
+	}
+	if _, err := os.Stat(common.ConfigFile); err != nil {
+		if len(common.Profile) > 0 {
+			panic("-profile " + common.Profile + " is provided but can not read the config file: " + err.Error())
+		}
+		return
+	}
+	values, err := lib.GetConfigProfile(common.ConfigFile, common.Profile)
+	if err != nil {
+		panic("Reading the config file failed: " + err.Error())
+	}
+	specified := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		specified[f.Name] = true
+	})
+	for key, value := range values {
+		if key == "config" || key == "profile" {
+			h.Log("WARN", fmt.Sprintf("'%s' can not be set in the config file %s", key, common.ConfigFile))
+			continue
+		}
+		if flag.Lookup(key) == nil {
+			h.Log("WARN", fmt.Sprintf("Unknown flag '%s' in the config file %s", key, common.ConfigFile))
+			continue
+		}
+		if specified[key] {
+			// The command line flags override the config file
+			continue
+		}
+		if err = flag.Set(key, value); err != nil {
+			panic(fmt.Sprintf("Invalid value for '%s' in the config file %s: %s", key, common.ConfigFile, err.Error()))
+		}
+	}
+}
+
 // Populate all global variables
 func setGlobals() {
 	common.StartTimestamp = time.Now().Unix()
-	// TODO: Read from $HOME/.filelist_config file and populate the default values
-	// TODO: (low) 'b' should accept the comma separated values for supporting the group blob store
-	flag.StringVar(&common.BaseDir, "b", "", "Blob store directory or URI (eg. 's3://s3-test-bucket/s3-test-prefix/'), which location contains 'content' directory (default: '.')")
+	flag.StringVar(&common.ConfigFile, "config", lib.DefaultConfigPath(), "Config file to populate the default values of the flags (default: $HOME/"+lib.CONFIG_FILE_NAME+")")
+	flag.StringVar(&common.Profile, "profile", "", "Profile name ([section]) in the config file. The flags without section are used as 'default'")
+	flag.StringVar(&common.BaseDir, "b", "", "Blob store directory or URI (eg. 's3://s3-test-bucket/s3-test-prefix/'), which location contains 'content' directory (default: '.'). Comma separated for the group blob store members")
 	flag.StringVar(&common.BaseDir2, "bTo", "", "*Experimental* Blob store directory or URI (eg. 's3://s3-test-bucket/s3-test-prefix_to_content/') for copying files from -b")
 	flag.StringVar(&common.B2RepoName, "bTo-repoName", "", "*Experimental* Replace the repository name (@Blobstore.blob-name) when copy")
 	flag.BoolVar(&common.B2NewBlobId, "bTo-NewBlobId", false, "*Experimental* Regenerate new UUID for the part of the filename (Blob ID)")
+	flag.StringVar(&common.B2MappingFile, "bTo-Mapping", "", "With -bTo-NewBlobId, save the old -> new blob refs (blob IDs if no -bsName) into this file (default: ./filelist2_blobid_mapping_{timestamp}.tsv)")
+	flag.StringVar(&common.B2SqlFile, "bTo-SQL", "", "With -bTo-NewBlobId and -bTo-repoName, generate the SQL file to update <format>_asset_blob.blob_ref of the repository")
 	flag.BoolVar(&common.B2PropsOnly, "bTo-PropsOnly", false, "*Experimental* Properties file only (no .bytes files copied)")
+	flag.BoolVar(&common.B2Verify, "bTo-Verify", false, "Verify the copied .bytes with the size and the sha1 in the .properties (reads the destination .bytes)")
+	flag.BoolVar(&common.B2VerifySha256, "bTo-Verify-sha256", false, "With -bTo-Verify, also compare the SHA-256 computed from both source and destination .bytes")
 	flag.BoolVar(&common.NoDateBsLayout, "NoDateBS", false, "Declair the date based blob store layout (YYYY/MM/DD/hh/mm/uuid) is not used, so that force checking the old vol-XX/chap-XX/uuid layout")
 	flag.StringVar(&common.Filter4Path, "p", "/(vol-\\d\\d|20\\d\\d)/", "Regular Expression for directory *path* (default: '/(vol-\\d\\d|20\\d\\d)/'), or S3 prefix.")
 	flag.StringVar(&common.Filter4FileName, "f", "", "Regular Expression for the file *name* (eg: '\\.properties' to include only this extension)")
 	flag.BoolVar(&common.WithProps, "P", false, "If true, the .properties file content is included in the output")
 	flag.StringVar(&common.Filter4PropsIncl, "pRx", "", "Regular Expression against the text of the .properties files (eg: 'deleted=true')")
 	flag.StringVar(&common.Filter4PropsExcl, "pRxExcl", "", "Excluding Regular Expression for .properties files (eg: 'BlobStore.blob-name=.+/maven-metadata.xml.*')")
-	// TODO: (low) not implemented yet
-	flag.StringVar(&common.Filter4PropsNot, "pRxNot", "", "Regular Expression for finding .properties files which does not contain this regex (eg: 'BlobStore.content-type')")
-	//flag.StringVar(&common.Filter4BytesIncl, "bRx", "", "Regular Expression for .bytes files (max size 32KB)")
-	//flag.StringVar(&common.Filter4BytesExcl, "bRxNot", "", "Excluding Regular Expression for .bytes files (max size 32KB)")
+	flag.StringVar(&common.Filter4PropsNot, "pRxNot", "", "Regular Expression for finding .properties files which does NOT contain this regex (eg: 'BlobStore.content-type')")
+	flag.StringVar(&common.Filter4BytesIncl, "bRx", "", "Regular Expression against the contents of the .bytes files (only the first -bRxMax bytes, and binary archives are skipped)")
+	flag.StringVar(&common.Filter4BytesExcl, "bRxNot", "", "Regular Expression for finding .bytes files which does NOT contain this regex (only the first -bRxMax bytes, and binary archives are skipped)")
+	flag.Int64Var(&common.BytesRxMaxSize, "bRxMax", 32768, "Max size (bytes) to read from each .bytes file for -bRx and -bRxNot")
 	flag.StringVar(&common.SaveToFile, "s", "", "Save the output (TSV text) into the specified path")
 	flag.BoolVar(&common.SavePerDir, "SavePerDir", false, "If true and -s is given, save the output per sub-directory")
+	flag.BoolVar(&common.Resume, "resume", false, "If true, skip the sub-directories completed by the previous run (recorded in {-s}"+lib.CHECKPOINT_EXT+") and append to the same -s output")
 	// TODO: no centralised place to control this topN
 	flag.Int64Var(&common.TopN, "n", 0, "Print first N lines (0 = no limit). If -c is greater than 1, it could return more than N lines.")
 	flag.IntVar(&common.Conc1, "c", 1, "Concurrent number for reading directories")
-	flag.IntVar(&common.Conc2, "c2", 8, "2nd Concurrent number. Currently used when retrieving object from AWS S3")
+	flag.IntVar(&common.Conc2, "c2", 8, "2nd Concurrent number. Currently used when retrieving object from AWS S3, and the max number of .bytes read at once for -integrity")
 	// TODO: probably the depth is not needed?
 	flag.IntVar(&common.MaxDepth, "depth", -1, "Max Depth for finding sub-directories only for File type (default: -1 for auto)")
 	flag.BoolVar(&common.NotCompSubDirs, "NotCompSubDirs", false, "Disable automatic sub-directories computation")
+	flag.StringVar(&common.S3Inventory, "s3Inv", "", "S3 Inventory manifest.json (local path or 's3://inventory-bucket/.../manifest.json') to use the paths, sizes and mtimes from the report instead of listing the S3 blob store (-b)")
 	//flag.BoolVar(&common.WalkRecursive, "WalkRecursive", true, "If true, recursively walk the directories under 'content'")
 	flag.BoolVar(&common.NoHeader, "H", false, "If true, no header line")
+	flag.StringVar(&common.MetricsAddr, "metricsAddr", "", "Address (eg. ':9090') to expose the Prometheus metrics at /metrics while running")
+	flag.DurationVar(&common.MetricsLinger, "metricsLinger", 0, "With -metricsAddr, keep /metrics for this duration (eg. '2m') after the job completed, so that the final values are scraped")
+	flag.IntVar(&common.ProgressSec, "progress", 0, "Interval seconds to log the progress (dirs done/total, counters, throughput and ETA). The progress is also logged on SIGUSR1 (0 = only on SIGUSR1)")
+	flag.BoolVar(&common.Summary, "summary", false, "If true, print the summary report (per repository, content-type, month, soft-deleted vs. live) instead of each line. Saved as JSON with -s")
+	flag.StringVar(&common.OutputFormat, "format", "tsv", "Output format: 'tsv', 'csv', 'jsonl' (JSON Lines, no header, and the .properties contents as a nested object) or 'parquet' (requires -s)")
 
 	flag.StringVar(&common.BlobIDFIle, "rF", "", "Result File which contains the list of blob IDs")
-	// TODO: GetXxxx not used yet
-	//flag.StringVar(&common.GetFile, "get", "", "TODO: Get a single file/blob from the blob store")
-	//flag.StringVar(&common.GetTo, "getTo", "", "TODO: Get (copy) to the local path")
+	flag.StringVar(&common.BlobIDFIleType, "rFType", "", "'BS' or 'DB'. Type (source) of the -rF file. If different from -src, the file is compared with -src (default: auto)")
+	flag.StringVar(&common.GetFile, "get", "", "Get (download) a single blob (.properties and .bytes) by the blob ID, blob ref or path from the blob store (-b)")
+	flag.StringVar(&common.GetTo, "getTo", ".", "Local directory to save the blob files from -get")
 
 	// DB / SQL related
-	flag.StringVar(&common.DbConnStr, "db", "", "DB connection string or path to DB connection properties file")
+	flag.StringVar(&common.DbConnStr, "db", "", "DB connection string, path to DB connection properties file (nexus-store.properties), or SQLite file. For H2, not the H2 DB file but nexus-store.properties with jdbc:h2:, and the H2 PG server mode should be running (localhost:5435 or H2_PG_PORT)")
+	flag.StringVar(&common.DbType, "dbType", "", "DB type: 'postgres', 'h2' or 'sqlite' (default: detected from -db). 'h2' connects to the H2 PG server mode (the H2 DB file can not be opened directly)")
 	// This is now almost useless as used only for filtering repositories.
 	flag.StringVar(&common.BsName, "bsName", "", "Eg. 'default'. If provided, the SQL query *may* become *slightly* faster")
 	flag.StringVar(&common.QRepoNames, "qRepos", "", "Experimental: Comma separated repository names used to generate SQL query")
 	flag.StringVar(&common.Query, "query", "", "SQL 'SELECT blob_id ...' or 'SELECT blob_ref as blob_id ...' to filter the data from the DB")
+	flag.BoolVar(&common.DbStream, "dbStream", false, "With -src DB, stream all asset blobs of the blob store (-bsName) or -qRepos from the DB, and output the ones which .properties or .bytes is missing (no -query/-rF needed)")
+	flag.BoolVar(&common.RepairProps, "repairProps", false, "With -dbStream, write the .properties regenerated from the DB for the blobs which have only .bytes (recorded in -journal, or only report with -dryRun)")
+	flag.BoolVar(&common.DbBulk, "dbBulk", false, "With -src BS, load all blob refs of the blob store (-bsName) or -qRepos from the DB into memory first, then check each blob without the query per blob")
 
 	// Reconcile / orphaned blob finding related
 	flag.StringVar(&common.Truth, "src", "", "Source of the Truth. If 'BS' (blobstore), it works similar to Orphaned blobs finder. If 'DB', similar to Dead blobs finder.")
 	// TODO: Not enough testing the `-RDel` with the new blob store layout and with S3 / Azure
 	flag.BoolVar(&common.RemoveDeleted, "RDel", false, "Remove 'deleted=true' from .properties. Requires -dF")
 	flag.StringVar(&common.WriteIntoStr, "wStr", "", "For testing. Write the string into the file (eg. deleted=true)")
+	flag.StringVar(&common.JournalFile, "journal", "", "Journal file to record the original contents/tags before -RDel or -wStr modifies, or the files created by -repairProps (default: ./filelist2_journal_{timestamp}.jsonl)")
+	flag.StringVar(&common.UndoJournal, "undo", "", "Restore the original contents/tags recorded in this journal file (requires -b)")
 	flag.StringVar(&common.DelDateFromStr, "dDF", "", "Deleted date in *UTC* with ISO format (from/since). Used to search deletedDateTime")
 	flag.StringVar(&common.DelDateToStr, "dDT", "", "Deleted date in *UTC* with ISO format (to/until/upto). To exclude newly deleted assets")
 	flag.StringVar(&common.ModDateFromStr, "mDF", "", "File modification date in *UTC* with ISO format (from/since)")
 	flag.StringVar(&common.ModDateToStr, "mDT", "", "File modification date in *UTC* with ISO format (to/until/upto)")
 	flag.BoolVar(&common.BytesChk, "BytesChk", false, "Check if .bytes file exists. Also the .bytes mod time is used for -mDF/-mDT")
 	flag.BoolVar(&common.NoExtraChk, "NoExChk", false, "Do not perform extra checks such as the file size to improve performance")
+	flag.BoolVar(&common.Integrity, "integrity", false, "Read all .bytes to compare the size and sha1 with size= and sha1= in .properties (-c2 .bytes are read at once). Only the blobs with the issue are output")
 
 	// Blob store specifics (AWS S3 / Azure related)
 	flag.IntVar(&common.MaxKeys, "m", 1000, "AWS S3: Integer value for Max Keys (<= 1000)")
 	flag.BoolVar(&common.WithOwner, "O", false, "AWS S3: If true, get the owner display name")
-	flag.BoolVar(&common.WithTags, "T", false, "AWS S3: If true, get tags of each object")
+	flag.BoolVar(&common.WithTags, "T", false, "AWS S3: If true, get tags of each object. Azure: the metadata and the blob index tags")
+	flag.BoolVar(&common.AzDeleted, "azDeleted", false, "Azure: If true, list only the soft-deleted blobs and the previous versions (Path ends with '"+bs_clients.AZ_VERSION_QUERY+"{id}'), with the AzState column")
+	flag.BoolVar(&common.AzUndelete, "azUndelete", false, "Azure: With -azDeleted, undelete the listed soft-deleted blobs, or copy the latest previous version when the current blob does not exist")
 	flag.BoolVar(&common.S3PathStyle, "PathStyle", false, "AWS S3: If true, use older path style (eg. http://s3.amazonaws.com/BUCKET/KEY)")
 
 	// Other options for troubleshooting
 	flag.Int64Var(&common.SlowMS, "slowMS", 1000, "Some methods show WARN log if that method takes more than this msec")
+	flag.Float64Var(&common.MaxRps, "rps", 0, "Max requests/second to the blob stores, shared by all goroutines (0 = unlimited). Reduced automatically while throttled")
+	flag.Int64Var(&common.MaxBps, "bps", 0, "Max bytes/second to read/write from/to the blob stores, shared by all goroutines (0 = unlimited)")
+	flag.IntVar(&common.RetryMax, "retry", 3, "Max attempts of reading the blob store (ReadPath, GetFileInfo and GetReader) when the error is transient (1 = no retry). S3, Azure and Google SDKs also retry per attempt, so the attempts multiply")
+	flag.Int64Var(&common.RetryMS, "retryMS", 500, "The first back-off msec of -retry (doubled per attempt, up to 30 seconds)")
+	flag.StringVar(&common.RetryRx, "retryRx", "", "Regular expression for the error messages to retry (default: the backend decides, eg. 5xx, 429 and network errors)")
+	flag.StringVar(&common.ErrorsFile, "errF", "", "File to save the paths which still failed after -retry, for re-running with -rF (default: -s + '.errors' or ./filelist2_errors_{timestamp}.tsv)")
 	flag.IntVar(&common.CacheSize, "cacheSize", 1000, "How many .properties files to cache")
 	flag.BoolVar(&common.Debug, "X", false, "If true, verbose logging")
 	flag.BoolVar(&common.Debug2, "XX", false, "If true, more verbose logging (currently only for AWS")
-	//flag.BoolVar(&common.DryRun, "Dry", false, "If true, RDel does not do anything")	# No longer needed as -rF can be used
+	flag.BoolVar(&common.DryRun, "dryRun", false, "If true, -RDel, -wStr, -undo, -azUndelete and -repairProps only report (log) what would change")
 
 	flag.Parse()
+	applyConfigProfile()
 
 	if common.Debug2 {
 		common.Debug2 = true
@@ -114,18 +185,14 @@ func setGlobals() {
 	h.DEBUG = common.Debug
 
 	h.Log("DEBUG", "Starting setGlobals for "+strings.Join(os.Args[1:], " "))
+	h.Log("DEBUG", "common.ConfigFile = "+common.ConfigFile+", common.Profile = "+common.Profile)
 	h.Log("DEBUG", "common.BaseDir = "+common.BaseDir)
 	if len(common.BaseDir) > 0 {
-		common.BaseDir = h.AppendSlash(common.BaseDir)
-		h.Log("DEBUG", "common.BaseDir with slash = "+common.BaseDir)
-		common.BsType = lib.GetSchema(common.BaseDir)
-		h.Log("DEBUG", "common.BsType = "+common.BsType)
-		// if the BaseDir starts with "s3://", get hostname as the bucket name, and the rest as the prefix
-		common.Container, common.Prefix = lib.GetContainerAndPrefix(common.BaseDir)
-		h.Log("DEBUG", "common.Container = "+common.Container)
-		h.Log("DEBUG", "common.Prefix = "+common.Prefix)
-		common.ContentPath = lib.GetContentPath(common.BaseDir, common.Container)
-		h.Log("DEBUG", "common.ContentPath = "+common.ContentPath)
+		common.GroupMembers = lib.SplitGroupMembers(common.BaseDir)
+		if len(common.GroupMembers) > 1 {
+			h.Log("INFO", fmt.Sprintf("Group blob store with %d members: %v", len(common.GroupMembers), common.GroupMembers))
+		}
+		useMember(0)
 	}
 
 	if len(common.BaseDir2) > 0 {
@@ -139,7 +206,54 @@ func setGlobals() {
 		common.ContentPath2 = lib.GetContentPath(common.BaseDir2, common.Container2)
 		h.Log("DEBUG", "common.ContentPath2 = "+common.ContentPath2)
 	}
+	if common.B2VerifySha256 {
+		common.B2Verify = true
+	}
+	if common.B2NewBlobId && len(common.BaseDir2) > 0 && len(common.B2MappingFile) == 0 {
+		common.B2MappingFile = lib.DefaultBlobIdMappingPath()
+	}
+	if len(common.B2SqlFile) > 0 && (!common.B2NewBlobId || len(common.B2RepoName) == 0) {
+		h.Log("ERROR", "-bTo-SQL requires -bTo-NewBlobId and -bTo-repoName.")
+		os.Exit(1)
+	}
+	if common.B2Verify && (len(common.BaseDir2) == 0 || common.B2PropsOnly) {
+		h.Log("ERROR", "-bTo-Verify requires -bTo and can not be used with -bTo-PropsOnly.")
+		os.Exit(1)
+	}
+
+	if common.OutputFormat != "tsv" && common.OutputFormat != "csv" && common.OutputFormat != "jsonl" && common.OutputFormat != "parquet" {
+		h.Log("ERROR", "-format should be 'tsv', 'csv', 'jsonl' or 'parquet': "+common.OutputFormat)
+		os.Exit(1)
+	}
+
+	if common.MaxRps < 0 || common.MaxBps < 0 {
+		h.Log("ERROR", "-rps or -bps is lower than 0.")
+		os.Exit(1)
+	}
+	lib.InitRateLimits(common.MaxRps, common.MaxBps)
+	if common.RetryMax < 1 || common.RetryMS < 0 {
+		h.Log("ERROR", "-retry is lower than 1 or -retryMS is lower than 0.")
+		os.Exit(1)
+	}
+	if len(common.RetryRx) > 0 {
+		common.RxRetry = regexp.MustCompile(common.RetryRx)
+	}
+	if len(common.ErrorsFile) == 0 {
+		if len(common.SaveToFile) > 0 {
+			common.ErrorsFile = common.SaveToFile + ".errors"
+		} else {
+			common.ErrorsFile = "filelist2_errors_" + time.Now().Format("20060102150405") + ".tsv"
+		}
+	}
+	lib.SetErrorsFile(common.ErrorsFile)
+	if common.MaxRps > 0 || common.MaxBps > 0 {
+		h.Log("INFO", fmt.Sprintf("Limiting requests/second:%.1f, bytes/second:%d (0 = unlimited)", common.MaxRps, common.MaxBps))
+	}
 
+	if common.ProgressSec < 0 {
+		h.Log("ERROR", "-progress is lower than 0.")
+		os.Exit(1)
+	}
 	if common.Conc1 < 1 {
 		h.Log("ERROR", "-c is lower than 1.")
 		os.Exit(1)
@@ -152,8 +266,22 @@ func setGlobals() {
 	if len(common.DbConnStr) > 0 {
 		// If it's nexus-store.properties file, read the file and get the DB connection string
 		if _, err := os.Stat(common.DbConnStr); err == nil {
-			common.DbConnStr = lib.GenDbConnStrFromFile(common.DbConnStr)
+			if lib.IsSqliteFile(common.DbConnStr) {
+				if len(common.DbType) == 0 {
+					common.DbType = lib.DB_TYPE_SQLITE
+				}
+			} else {
+				var dbType string
+				common.DbConnStr, dbType = lib.GenDbConnStrFromFile(common.DbConnStr)
+				if len(common.DbType) == 0 {
+					common.DbType = dbType
+				}
+			}
 		}
+		if err := lib.SetDialect(common.DbType); err != nil {
+			panic(err)
+		}
+		h.Log("DEBUG", "DB type: "+lib.GetDialect().Name())
 		// Try connecting to the DB to get the repository name and format
 		common.DB = lib.OpenDb(common.DbConnStr)
 		if common.Conc1 > 0 {
@@ -217,6 +345,20 @@ func setGlobals() {
 		}
 	}
 
+	if len(common.UndoJournal) > 0 {
+		if len(common.BaseDir) == 0 {
+			panic("-undo requires -b")
+		}
+		if common.RemoveDeleted || len(common.WriteIntoStr) > 0 || len(common.BlobIDFIle) > 0 || len(common.Query) > 0 || len(common.BaseDir2) > 0 {
+			panic("-undo can not be used with -RDel, -wStr, -rF, -query or -bTo")
+		}
+	} else if (common.RemoveDeleted || len(common.WriteIntoStr) > 0 || common.RepairProps) && !common.DryRun && len(common.JournalFile) == 0 {
+		common.JournalFile = lib.DefaultJournalPath()
+	}
+	if common.DryRun && !common.RemoveDeleted && len(common.WriteIntoStr) == 0 && len(common.UndoJournal) == 0 && !common.AzUndelete && !common.RepairProps {
+		h.Log("WARN", "-dryRun is given but no -RDel, -wStr, -undo, -azUndelete or -repairProps")
+	}
+
 	// If _FILTER_P is given, automatically populate other related variables
 	if len(common.Filter4PropsIncl) > 0 {
 		common.RxIncl, _ = regexp.Compile(common.Filter4PropsIncl)
@@ -224,15 +366,21 @@ func setGlobals() {
 	if len(common.Filter4PropsExcl) > 0 {
 		common.RxExcl, _ = regexp.Compile(common.Filter4PropsExcl)
 	}
+	if len(common.Filter4PropsNot) > 0 {
+		common.RxNot, _ = regexp.Compile(common.Filter4PropsNot)
+	}
 	if len(common.Filter4BytesIncl) > 0 {
 		common.RxInclBytes, _ = regexp.Compile(common.Filter4BytesIncl)
 	}
 	if len(common.Filter4BytesExcl) > 0 {
 		common.RxExclBytes, _ = regexp.Compile(common.Filter4BytesExcl)
 	}
+	if (common.RxInclBytes != nil || common.RxExclBytes != nil) && common.BytesRxMaxSize < 1 {
+		panic("-bRxMax should be greater than 0")
+	}
 
 	if len(common.Filter4FileName) == 0 {
-		if (len(common.Truth) > 0 && len(common.DbConnStr) > 0) || (len(common.Filter4PropsIncl) > 0 || len(common.Filter4PropsExcl) > 0) || common.RemoveDeleted || len(common.BaseDir2) > 0 {
+		if (len(common.Truth) > 0 && len(common.DbConnStr) > 0) || (len(common.Filter4PropsIncl) > 0 || len(common.Filter4PropsExcl) > 0 || len(common.Filter4PropsNot) > 0) || (len(common.Filter4BytesIncl) > 0 || len(common.Filter4BytesExcl) > 0) || common.RemoveDeleted || len(common.BaseDir2) > 0 || common.Integrity {
 			// If Truth is set and a DB connection is provided, probably want to check only .properties files
 			h.Log("INFO", "Setting '-f "+common.PROPERTIES+"'.")
 			common.Filter4FileName = common.PROPERTIES
@@ -256,6 +404,30 @@ func setGlobals() {
 		}
 	}
 
+	if common.Integrity {
+		if len(common.BaseDir) == 0 || len(common.Truth) > 0 || len(common.BaseDir2) > 0 {
+			panic("-integrity requires -b, and can't be used with -src or -bTo")
+		}
+		// The .bytes existence is checked first
+		common.BytesChk = true
+		IntegrityGuard = make(chan struct{}, max(common.Conc2, 1))
+	}
+	if common.DbStream {
+		if common.Truth != "DB" || len(common.DbConnStr) == 0 || len(common.BaseDir) == 0 {
+			panic("-dbStream requires -src DB with -b and -db")
+		}
+		if len(common.BlobIDFIle) > 0 || (len(common.Query) > 0 && len(common.QRepoNames) == 0) {
+			panic("-dbStream can't be used with -rF or -query")
+		}
+		// The repositories of -qRepos are used directly, so not saving the query result into a file
+		common.Query = ""
+	}
+	if common.RepairProps && !common.DbStream {
+		panic("-repairProps requires -src DB with -dbStream")
+	}
+	if common.DbBulk && (common.Truth != "BS" || len(common.DbConnStr) == 0 || len(common.BaseDir) == 0) {
+		panic("-dbBulk requires -src BS with -b and -db")
+	}
 	if common.Truth == "BS" || common.Truth == "DB" {
 		if len(common.BlobIDFIle) == 0 && len(common.Query) == 0 && (len(common.DbConnStr) == 0 || len(common.BaseDir) == 0) {
 			panic("-src requires -rF or -b with -db")
@@ -271,6 +443,9 @@ func setGlobals() {
 		// TODO: support for BaseDir2
 		panic("Currently -b or -db is required with -rF")
 	}
+	if len(common.BlobIDFIleType) > 0 && common.BlobIDFIleType != "BS" && common.BlobIDFIleType != "DB" {
+		panic("-rFType should be 'BS' or 'DB': " + common.BlobIDFIleType)
+	}
 	if len(common.BlobIDFIle) > 0 && len(common.BlobIDFIleType) == 0 {
 		if len(common.DbConnStr) > 0 && len(common.BaseDir) > 0 {
 			h.Log("DEBUG", "-b, and -db are given. Using Blob IDs in -rF as if saved BS output.")
@@ -284,6 +459,51 @@ func setGlobals() {
 		}
 	}
 
+	if len(common.GroupMembers) > 1 && len(common.BaseDir2) > 0 && (len(common.BlobIDFIle) > 0 || len(common.Query) > 0) {
+		panic("Currently copying with -rF from the group blob store (comma separated -b) is not supported")
+	}
+
+	if common.Resume && len(common.SaveToFile) == 0 {
+		panic("-resume requires -s")
+	}
+	if common.AzUndelete && !common.AzDeleted {
+		panic("-azUndelete requires -azDeleted")
+	}
+	if common.AzDeleted {
+		// The soft-deleted blobs and the previous versions are not the normal blobs, so only listing (and undeleting) them
+		if common.BsType != "az" || len(common.Truth) > 0 || common.BytesChk || len(common.BaseDir2) > 0 || common.RemoveDeleted || len(common.WriteIntoStr) > 0 || len(common.BlobIDFIle) > 0 || len(common.Query) > 0 || common.OutputFormat == "parquet" || common.Summary {
+			panic("-azDeleted is only for listing the Azure blob store (-b az://...) without -src, -BytesChk, -bTo, -RDel, -wStr, -rF, -query, -format parquet or -summary")
+		}
+	}
+	if len(common.S3Inventory) > 0 {
+		if common.BsType != "s3" || len(common.GroupMembers) > 1 || len(common.BlobIDFIle) > 0 || len(common.Query) > 0 || len(common.GetFile) > 0 || common.Resume {
+			panic("-s3Inv is only for listing the S3 blob store (-b s3://...) without the group members, -rF, -query, -get or -resume")
+		}
+	}
+	if common.Summary {
+		if len(common.BaseDir) == 0 || len(common.GetFile) > 0 || len(common.BaseDir2) > 0 || common.OutputFormat == "parquet" || common.SavePerDir || common.Resume {
+			panic("-summary is only for listing the blob store (-b) without -get, -bTo, -format parquet, -SavePerDir or -resume")
+		}
+		if !common.WithProps {
+			h.Log("INFO", "-summary needs the .properties contents, so setting -P")
+			common.WithProps = true
+		}
+		// Not printing each line, so no header
+		common.NoHeader = true
+	}
+	if common.OutputFormat == "parquet" {
+		// The Parquet columns are fixed to the listing output (lib.ParquetRow)
+		if len(common.SaveToFile) == 0 || len(common.BaseDir) == 0 {
+			panic("-format parquet requires -b and -s")
+		}
+		if len(common.GetFile) > 0 || (len(common.BlobIDFIle) > 0 && (common.BlobIDFIleType != "DB" || (len(common.Truth) > 0 && common.Truth != "DB") || len(common.BaseDir2) > 0)) {
+			panic("-format parquet is only for listing the blob store (-b), or checking the blob IDs from DB (-rF or -query) in the blob store")
+		}
+		if !common.WithProps {
+			h.Log("INFO", "-format parquet includes the columns from the .properties file, so setting -P")
+			common.WithProps = true
+		}
+	}
 	if len(common.SaveToFile) > 0 {
 		if len(common.BlobIDFIle) > 0 {
 			// If the actual SaveToFile and BlobIDFIle are the same, panic
@@ -296,9 +516,32 @@ func setGlobals() {
 
 		// If the SaveToFile is a directory, set SavePerDir to true
 		if fi, err := os.Stat(common.SaveToFile); err == nil && fi.IsDir() {
+			if common.Summary {
+				panic("-summary can not save into the directory: " + common.SaveToFile)
+			}
 			h.Log("DEBUG", "Save to destination is directory. Setting SavePerDir to true. "+common.SaveToFile)
 			common.SavePerDir = true
 		}
+		// The completed sub-directories are recorded, so that the listing can be resumed (only for the listing mode)
+		if len(common.BaseDir) > 0 && len(common.BlobIDFIle) == 0 && len(common.Query) == 0 && len(common.S3Inventory) == 0 && !common.Summary {
+			common.CheckpointFile = strings.TrimSuffix(common.SaveToFile, string(filepath.Separator)) + lib.CHECKPOINT_EXT
+			h.Log("DEBUG", "common.CheckpointFile = "+common.CheckpointFile)
+		}
+		if common.Resume {
+			if len(common.CheckpointFile) == 0 {
+				panic("-resume is only for listing the blob store (-b) without -rF or -query")
+			}
+			if common.OutputFormat == "parquet" && !common.SavePerDir {
+				panic("-resume with -format parquet requires -SavePerDir, as a Parquet file can not be appended")
+			}
+			if fi, err := os.Stat(common.SaveToFile); err == nil && !fi.IsDir() && fi.Size() > 0 {
+				if _, err = os.Stat(common.CheckpointFile); err != nil {
+					panic("-resume is given and " + common.SaveToFile + " is not empty, but no checkpoint file: " + common.CheckpointFile)
+				}
+				// Appending to the previous output, so no header
+				common.NoHeader = true
+			}
+		}
 		var err error
 		if common.SavePerDir {
 			// Header is written only when one file is used (otherwise, when concatenating files, it will be problem)
@@ -310,7 +553,8 @@ func setGlobals() {
 				}
 			}
 			h.Log("INFO", "Output will be saved into the directory: "+common.SaveToFile)
-		} else {
+		} else if common.OutputFormat != "parquet" && !common.Summary {
+			// Parquet file is opened by lib.WriteParquetLine, and the summary is written by printSummary
 			common.SaveToPointer, err = os.OpenFile(common.SaveToFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
 				panic(err)
@@ -319,16 +563,32 @@ func setGlobals() {
 	}
 }
 
+// useMember switches the blob store related global variables and the Client to the group member
+func useMember(idx int) {
+	common.CurrentMember = common.GroupMembers[idx]
+	common.BaseDir = h.AppendSlash(common.CurrentMember)
+	h.Log("DEBUG", "common.BaseDir with slash = "+common.BaseDir)
+	common.BsType = lib.GetSchema(common.BaseDir)
+	h.Log("DEBUG", "common.BsType = "+common.BsType)
+	// if the BaseDir starts with "s3://", get hostname as the bucket name, and the rest as the prefix
+	common.Container, common.Prefix = lib.GetContainerAndPrefix(common.BaseDir)
+	h.Log("DEBUG", "common.Container = "+common.Container)
+	h.Log("DEBUG", "common.Prefix = "+common.Prefix)
+	common.ContentPath = lib.GetContentPath(common.BaseDir, common.Container)
+	h.Log("DEBUG", "common.ContentPath = "+common.ContentPath)
+	// Azure caches the container client, which is per container (member)
+	bs_clients.AzContainer = nil
+	Client = bs_clients.WithRetry(bs_clients.GetClient(common.BsType))
+	Client.SetClientNum(1)
+}
+
 // Initialize _REPO_TO_FMT and _ASSET_TABLES
 func initRepoFmtMap(db *sql.DB) {
 	// Not sure if needed, but resetting the map and slice
 	common.Repo2Fmt = make(map[string]string)
 	common.AssetTables = make([]string, 0)
 
-	query := "SELECT name, REGEXP_REPLACE(recipe_name, '-.+', '') AS fmt FROM repository"
-	if len(common.BsName) > 0 {
-		query += " WHERE attributes->'storage'->>'blobStoreName' = '" + common.BsName + "'"
-	}
+	query := lib.GetDialect().RepoFormatQuery(common.BsName)
 	rows := lib.Query(query, db, 50)
 	if rows == nil { // For unit tests
 		h.Log("DEBUG", fmt.Sprintf("No result with %s", query))
@@ -356,22 +616,34 @@ func initRepoFmtMap(db *sql.DB) {
 }
 
 func printHeader(saveToPointer *os.File) {
-	if !common.NoHeader {
-		header := fmt.Sprintf("Path%sLastModified%sSize", common.SEP, common.SEP)
-		// NOTE: do not change the Properties column order. It needs to be 4th.
-		if common.WithProps {
-			header += fmt.Sprintf("%sProperties", common.SEP)
-		}
-		if common.WithOwner {
-			header += fmt.Sprintf("%sOwner", common.SEP)
-		}
-		if common.WithTags {
-			header += fmt.Sprintf("%sTags", common.SEP)
-		}
-		if len(common.Truth) > 0 || common.BytesChk {
-			header += fmt.Sprintf("%sMisc.", common.SEP)
-		}
-		printOrSave(header, saveToPointer)
+	cols := []string{"Path", "LastModified", "Size"}
+	// NOTE: do not change the Properties column order. It needs to be 4th.
+	if common.WithProps {
+		cols = append(cols, "Properties")
+	}
+	if common.WithOwner {
+		cols = append(cols, "Owner")
+	}
+	if common.WithTags {
+		cols = append(cols, "Tags")
+	}
+	if common.AzDeleted {
+		cols = append(cols, "AzState")
+	}
+	if len(common.Truth) > 0 || common.BytesChk {
+		cols = append(cols, "Misc.")
+	}
+	if len(common.GroupMembers) > 1 {
+		cols = append(cols, "Member")
+	}
+	printColumns(cols, saveToPointer)
+}
+
+// printColumns sets the column names of the output, and prints the header line unless -H, jsonl or parquet
+func printColumns(cols []string, saveToPointer *os.File) {
+	common.OutputCols = cols
+	if !common.NoHeader && common.OutputFormat != "jsonl" && common.OutputFormat != "parquet" {
+		printOrSave(strings.Join(cols, common.SEP), saveToPointer)
 	}
 }
 
@@ -385,6 +657,7 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 
 	var bytesChkErr error
 	var bytesInfo bs_clients.BlobInfo
+	var integrityErr string
 	if strings.HasSuffix(path, common.PROP_EXT) {
 		// the properties file can not be empty (0 byte), but if already Error, no need another WARN
 		if !common.NoExtraChk && !bi.Error && bi.Size == 0 {
@@ -401,10 +674,9 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 		}
 	}
 
-	//TODO: use shouldSkipBecauseOfBytes()
-
 	var output string
 	var sortedOneLineProps string
+	var rawProps string
 	var skipReason error
 	if bi.Error {
 		// When Orphaned blob finder mode, do not output unreadable (properties) files, and probably already DEBUG level logged?
@@ -436,7 +708,7 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 		// If the .properties file is checked, depending on other flags, need to generate extra output
 		if shouldReadProps(path, modTimestamp) {
 			//h.Log("DEBUG", fmt.Sprintf("Extra info from properties is needed for '%s'", path))
-			sortedOneLineProps, skipReason = extraInfo(path)
+			sortedOneLineProps, rawProps, skipReason = extraInfo(path)
 			if skipReason != nil {
 				return "", skipReason
 			}
@@ -454,15 +726,26 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 						h.Log("WARN", fmt.Sprintf("path:%s has size mismatch between size=%s and .bytes (%d)", path, matches[1], bytesInfo.Size))
 					}
 				}
+				if common.Integrity {
+					integrityErr = checkIntegrity(path, sortedOneLineProps, bytesInfo)
+				}
 			}
 			//} else {
 			//	h.Log("DEBUG", fmt.Sprintf("Extra info from properties is NOT needed for '%s'", path))
 		}
+
+		// Checking the .bytes contents after the .properties, as reading .bytes is more expensive
+		if common.RxInclBytes != nil || common.RxExclBytes != nil {
+			skipReason = shouldSkipBecauseOfBytes(path)
+			if skipReason != nil {
+				return "", skipReason
+			}
+		}
 	}
 
 	// NOTE: make sure the output order is same as the printHeader
 	if common.WithProps {
-		output = fmt.Sprintf("%s%s%s", output, common.SEP, sortedOneLineProps)
+		output = fmt.Sprintf("%s%s%s", output, common.SEP, propsColumn(sortedOneLineProps, rawProps))
 	}
 
 	if common.WithOwner {
@@ -473,6 +756,16 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 		output = fmt.Sprintf("%s%s%s", output, common.SEP, bi.Tags)
 	}
 
+	if common.AzDeleted {
+		state := bi.State
+		if common.AzUndelete {
+			if result := azUndelete(bi); len(result) > 0 {
+				state = state + "|" + result
+			}
+		}
+		output = fmt.Sprintf("%s%s%s", output, common.SEP, state)
+	}
+
 	// "Misc." column
 	if len(common.Truth) > 0 {
 		if common.Truth == "BS" { // Orphaned blob finder mode
@@ -481,8 +774,9 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 			if len(common.DbConnStr) > 0 && bytesChkErr == nil {
 				blobId := lib.ExtractBlobIdFromString(path)
 				// If sortedOneLineProps is empty, the below may use expensive query
-				reason := isOrphanedBlob(sortedOneLineProps, blobId, db)
+				reason := isOrphanedBlob(sortedOneLineProps, blobId, bi.ModTime, db)
 				if len(reason) > 0 {
+					lib.CountOrphanedBlob()
 					output = fmt.Sprintf("%s%s%s", output, common.SEP, reason)
 				} else {
 					return "", errors.New("Blob ID: " + blobId + " exists in the DB")
@@ -512,6 +806,7 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 					// TODO: check blob-name (path) in .properties file with DB {format}_asset.path. Currently can't as DB result is not passed for "DB" mode
 					h.Log("DEBUG", fmt.Sprintf("Should check the name/path in %s", path))
 				}
+				lib.CountDeadBlob()
 				output = fmt.Sprintf("%s%s%s|%s", output, common.SEP, deadErrMsg, deadExtraInfo)
 			} else {
 				return "", errors.New("Path: " + path + " exists in the BS")
@@ -520,13 +815,31 @@ func genOutput(path string, bi bs_clients.BlobInfo, db *sql.DB) (string, error)
 	} else if bytesChkErr != nil {
 		//h.Log("DEBUG", fmt.Sprintf("path:%s has no .bytes file.", path))
 		output = fmt.Sprintf("%s%s%s", output, common.SEP, "BYTES_MISSING")
+	} else if common.Integrity {
+		if len(integrityErr) == 0 {
+			return "", errors.New("Path: " + path + " has no integrity issue")
+		}
+		output = fmt.Sprintf("%s%s%s", output, common.SEP, integrityErr)
 	} else if common.BytesChk && bytesChkErr == nil && !strings.HasSuffix(path, common.BYTES_EXT) {
 		output = fmt.Sprintf("%s%sbytes-modified:%s|size:%d", output, common.SEP, bytesInfo.ModTime, bytesInfo.Size)
 	}
 
+	// "Member" column for the group blob store (should be always the last column)
+	if len(common.GroupMembers) > 1 {
+		output = fmt.Sprintf("%s%s%s", output, common.SEP, common.CurrentMember)
+	}
 	return output, skipReason
 }
 
+// propsColumn returns the value of the Properties column.
+// jsonl and parquet parse the properties into the fields, so the raw contents are used as the sorted one line is lossy (e.g. ',' in the value).
+func propsColumn(sortedOneLineProps string, rawProps string) string {
+	if common.Summary || (common.OutputFormat != "jsonl" && common.OutputFormat != "parquet") {
+		return sortedOneLineProps
+	}
+	return strings.TrimRight(rawProps, "\r\n")
+}
+
 func shouldReadProps(path string, modTimestamp int64) bool {
 	if !strings.HasSuffix(path, common.PROP_EXT) {
 		// If the path is not properties file, no need to open the file
@@ -538,7 +851,7 @@ func shouldReadProps(path string, modTimestamp int64) bool {
 		h.Log("INFO", "Skipping path:"+path+" as recently modified ("+strconv.FormatInt(modTimestamp, 10)+" > "+strconv.FormatInt(common.StartTimestamp, 10)+")")
 		return false
 	}
-	if common.RemoveDeleted || common.WithProps || len(common.WriteIntoStr) > 0 || len(common.Filter4FileName) > 0 || len(common.Filter4PropsIncl) > 0 || len(common.Filter4PropsExcl) > 0 || common.DelDateFromTS > 0 || common.DelDateToTS > 0 {
+	if common.RemoveDeleted || common.WithProps || common.Integrity || len(common.WriteIntoStr) > 0 || len(common.Filter4FileName) > 0 || len(common.Filter4PropsIncl) > 0 || len(common.Filter4PropsExcl) > 0 || len(common.Filter4PropsNot) > 0 || common.DelDateFromTS > 0 || common.DelDateToTS > 0 {
 		// These common properties require to read the properties file
 		return true
 	}
@@ -556,8 +869,8 @@ func shouldReadProps(path string, modTimestamp int64) bool {
 	return false
 }
 
-func extraInfo(path string) (string, error) {
-	// This function returns the extra information (.properties contents) and the skip reason as error
+func extraInfo(path string) (string, string, error) {
+	// This function returns the extra information (.properties contents as the sorted one line and as is) and the skip reason as error
 	// Also does extra checks. For example, this may return "" with the error, when RxIncl or RxExcl filtered the contents.
 	var contents string
 	var err error
@@ -577,14 +890,14 @@ func extraInfo(path string) (string, error) {
 		if err != nil {
 			h.Log("ERROR", "(extraInfo) "+path+" returned error:"+err.Error())
 			// This (reading file error) is not the skip reason, so returning nil error.
-			return "", nil
+			return "", "", nil
 		}
 	}
 
 	if len(contents) == 0 {
 		h.Log("ERROR", "(extraInfo) "+path+" returned 0 size.")
 		// This (empty) is not the skip reason, so returning nil error.
-		return "", nil
+		return "", "", nil
 	}
 
 	// removeDel requires reading the contents (to avoid re-reading the same file), so executing in the extraInfo.
@@ -611,9 +924,9 @@ func extraInfo(path string) (string, error) {
 	sortedContents := lib.SortToSingleLine(contents)
 	err = shouldSkipThisContents(sortedContents)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return sortedContents, nil
+	return sortedContents, contents, nil
 }
 
 func shouldSkipThisContents(sortedContents string) error {
@@ -624,6 +937,10 @@ func shouldSkipThisContents(sortedContents string) error {
 		//} else if common.RxExcl != nil {
 		//	h.Log("DEBUG", fmt.Sprintf("common.RxExcl did not match with %s", sortedContents))
 	}
+	// As Golang regex does not support the negative lookahead, RxNot is used for finding the contents which do NOT contain the regex
+	if common.RxNot != nil && common.RxNot.MatchString(sortedContents) {
+		return errors.New(fmt.Sprintf("Matched with the not-contain regex: %s. Skipping.", common.RxNot.String()))
+	}
 	if common.RxIncl != nil && len(common.RxIncl.String()) > 0 {
 		if common.RxIncl.MatchString(sortedContents) {
 			return nil
@@ -632,22 +949,6 @@ func shouldSkipThisContents(sortedContents string) error {
 			return errors.New(fmt.Sprintf("Does NOT match with the regex: %s. Skipping.", common.RxIncl.String()))
 		}
 	}
-
-	if common.RxExclBytes != nil {
-		if common.RxExclBytes.MatchString(sortedContents) {
-			return errors.New(fmt.Sprintf("Matched with the exclude regex: %s. Skipping.", common.RxExcl.String()))
-		}
-	}
-	if common.RxInclBytes != nil {
-		if len(common.RxIncl.String()) > 0 {
-			if common.RxIncl.MatchString(sortedContents) {
-				return nil
-			} else {
-				//h.Log("DEBUG", fmt.Sprintf("Sorted content did not match with '%s'", common.RxIncl.String()))
-				return errors.New(fmt.Sprintf("Does NOT match with the regex: %s. Skipping.", common.RxIncl.String()))
-			}
-		}
-	}
 	return nil
 }
 
@@ -665,25 +966,78 @@ func bytesFileCheck(propPath string) (bytesInfo bs_clients.BlobInfo, bytesChkErr
 	return bytesInfo, bytesChkErr
 }
 
-/* func shouldSkipBecauseOfBytes(bytesContents string) error {
-	// TODO: not properly implemented and not tested
-	if common.RxExclBytes != nil {
-		if common.RxExclBytes.MatchString(bytesContents) {
-			return errors.New(fmt.Sprintf("Matched with the exclude regex: %s. Skipping.", common.RxExcl.String()))
-		}
+// checkIntegrity compares the .bytes with size= and sha1= in the .properties. Returns the INTEGRITY_* code for the Misc. column, or empty string if no issue.
+func checkIntegrity(propPath string, sortedOneLineProps string, bytesInfo bs_clients.BlobInfo) string {
+	matches := common.RxSizeByte.FindStringSubmatch(sortedOneLineProps)
+	if len(matches) < 2 {
+		return lib.INTEGRITY_NO_SIZE
 	}
-	if common.RxInclBytes != nil {
-		if len(common.RxIncl.String()) > 0 {
-			if common.RxIncl.MatchString(bytesContents) {
-				return nil
-			} else {
-				//h.Log("DEBUG", fmt.Sprintf("Sorted content did not match with '%s'", common.RxIncl.String()))
-				return errors.New(fmt.Sprintf("Does NOT match with the regex: %s. Skipping.", common.RxIncl.String()))
-			}
-		}
+	sizeInProps, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return lib.INTEGRITY_NO_SIZE
+	}
+	// No need to read the .bytes if the size is already different
+	if sizeInProps != bytesInfo.Size {
+		h.Log("WARN", fmt.Sprintf("path:%s has %s (size=%d, .bytes=%d)", propPath, lib.INTEGRITY_SIZE_MISMATCH, sizeInProps, bytesInfo.Size))
+		return fmt.Sprintf("%s:size=%d|bytes=%d", lib.INTEGRITY_SIZE_MISMATCH, sizeInProps, bytesInfo.Size)
+	}
+	IntegrityGuard <- struct{}{}
+	defer func() { <-IntegrityGuard }()
+	bytesPath := lib.GetPathWithoutExt(propPath) + common.BYTES_EXT
+	maybeReader, err := Client.GetReader(bytesPath)
+	if err != nil {
+		h.Log("WARN", fmt.Sprintf("Reading %s failed with %s", bytesPath, err.Error()))
+		return lib.INTEGRITY_READ_ERROR + ":" + err.Error()
+	}
+	reader := maybeReader.(io.ReadCloser)
+	defer reader.Close()
+	result := lib.CheckIntegrity(reader, sizeInProps, lib.GetSha1(sortedOneLineProps))
+	if len(result) > 0 {
+		h.Log("WARN", fmt.Sprintf("path:%s has %s", propPath, result))
+	}
+	return result
+}
+
+func readBytesHead(bytesPath string, maxSize int64) ([]byte, error) {
+	maybeReader, err := Client.GetReader(bytesPath)
+	if err != nil {
+		return nil, err
+	}
+	reader := maybeReader.(io.ReadCloser)
+	defer reader.Close()
+	// Not reading the whole file, as .bytes can be very large
+	return io.ReadAll(io.LimitReader(reader, maxSize))
+}
+
+func shouldSkipBecauseOfBytes(path string) error {
+	// This function checks the contents of .bytes file (up to BytesRxMaxSize) with RxExclBytes and RxInclBytes
+	bytesPath := path
+	if strings.HasSuffix(path, common.PROP_EXT) {
+		bytesPath = lib.GetPathWithoutExt(path) + common.BYTES_EXT
+	} else if !strings.HasSuffix(path, common.BYTES_EXT) {
+		return errors.New(fmt.Sprintf("path:%s is not a blob file. Skipping.", path))
+	}
+	bytesHead, err := readBytesHead(bytesPath, common.BytesRxMaxSize)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Reading %s failed with %s. Skipping.", bytesPath, err.Error()))
+	}
+	// Binary archives (jar, tgz, etc.) are not checked, as the regex wouldn't be useful against the compressed data
+	binaryType := lib.DetectBinaryType(bytesHead)
+	if len(binaryType) > 0 && common.Debug2 {
+		h.Log("DEBUG", fmt.Sprintf("%s is detected as %s", bytesPath, binaryType))
+	}
+	// Skipping for both -bRx and -bRxNot, otherwise all binaries would be listed as "does not contain"
+	if len(binaryType) > 0 {
+		return errors.New(fmt.Sprintf("%s is binary (%s). Skipping.", bytesPath, binaryType))
+	}
+	if common.RxExclBytes != nil && common.RxExclBytes.Match(bytesHead) {
+		return errors.New(fmt.Sprintf("Matched with the not-contain regex: %s for .bytes. Skipping.", common.RxExclBytes.String()))
+	}
+	if common.RxInclBytes != nil && !common.RxInclBytes.Match(bytesHead) {
+		return errors.New(fmt.Sprintf("Does NOT match with the regex: %s for .bytes. Skipping.", common.RxInclBytes.String()))
 	}
 	return nil
-}*/
+}
 
 func shouldBeUndeleted(contents string, path string) bool {
 	if len(contents) == 0 {
@@ -720,6 +1074,15 @@ func removeDel(contents string, path string) bool {
 		return false
 	}
 
+	if common.DryRun {
+		h.Log("INFO", fmt.Sprintf("DRY-RUN: would remove 'deleted=true' from path:%s", path))
+		return true
+	}
+	// Not modifying if the original state can not be recorded
+	if err := recordJournal("RDel", path, contents, true); err != nil {
+		h.Log("ERROR", fmt.Sprintf("Recording path:%s into the journal failed with %s. Not removing 'deleted=true'", path, err))
+		return false
+	}
 	err := Client.RemoveDeleted(path, contents)
 	if err != nil {
 		h.Log("ERROR", fmt.Sprintf("Removing 'deleted=true' for path:%s failed with %s", path, err))
@@ -728,6 +1091,25 @@ func removeDel(contents string, path string) bool {
 	return true
 }
 
+// azUndelete undeletes the soft-deleted blob or restores the previous version. Returns the result for the AzState column.
+func azUndelete(bi bs_clients.BlobInfo) string {
+	undeleteClient, ok := bs_clients.Unwrap(Client).(bs_clients.UndeleteClient)
+	if !ok {
+		return ""
+	}
+	if common.DryRun {
+		h.Log("INFO", fmt.Sprintf("DRY-RUN: would undelete path:%s (%s)", bi.Path, bi.State))
+		return "DRY_RUN"
+	}
+	result, err := undeleteClient.Undelete(bi)
+	if err != nil {
+		h.Log("ERROR", fmt.Sprintf("Undeleting path:%s failed with %s", bi.Path, err))
+		return "ERROR_UNDELETE"
+	}
+	h.Log("INFO", fmt.Sprintf("Undeleted path:%s (%s)", bi.Path, result))
+	return result
+}
+
 func appendStr(appending string, contents string, path string) bool {
 	var updatedContents string
 	if strings.HasSuffix(contents, "\n") {
@@ -735,6 +1117,14 @@ func appendStr(appending string, contents string, path string) bool {
 	} else {
 		updatedContents = fmt.Sprintf("%s\n%s\n", contents, appending)
 	}
+	if common.DryRun {
+		h.Log("INFO", fmt.Sprintf("DRY-RUN: would append '%s' into path:%s", appending, path))
+		return true
+	}
+	if err := recordJournal("wStr", path, contents, true); err != nil {
+		h.Log("ERROR", fmt.Sprintf("Recording path:%s into the journal failed with %s. Not appending '%s'", path, err, appending))
+		return false
+	}
 	err := Client.WriteToPath(path, updatedContents)
 	if err != nil {
 		h.Log("ERROR", fmt.Sprintf("Apeending '%s' into path:%s failed with %s", appending, path, err))
@@ -747,22 +1137,112 @@ func appendStr(appending string, contents string, path string) bool {
 	return true
 }
 
+// recordJournal records the original contents (and tags of .properties and .bytes if withTags) before modifying the path
+func recordJournal(action string, path string, contents string, withTags bool) error {
+	if Journal == nil {
+		return nil
+	}
+	entry := lib.JournalEntry{Action: action, BaseDir: common.BaseDir, Path: path, Contents: contents}
+	if tagClient, ok := bs_clients.AsTagClient(Client); ok && withTags {
+		entry.Tags = make(map[string]map[string]string)
+		for _, tagPath := range []string{path, lib.GetPathWithoutExt(path) + common.BYTES_EXT} {
+			tags, err := tagClient.GetTags(tagPath)
+			if err != nil {
+				return err
+			}
+			entry.Tags[tagPath] = tags
+		}
+	}
+	return Journal.Record(entry)
+}
+
+// undoFromJournal restores the contents/tags recorded in the journal file. If the same path is recorded multiple times, the first (oldest) one is used.
+func undoFromJournal(journalPath string) error {
+	printColumns([]string{"Path", "Action", "Result"}, common.SaveToPointer)
+	restored := make(map[string]bool)
+	return lib.ReadJournal(journalPath, func(entry lib.JournalEntry) error {
+		atomic.AddInt64(&common.CheckedNum, 1)
+		key := entry.BaseDir + common.SEP + entry.Path
+		if restored[key] {
+			h.Log("DEBUG", fmt.Sprintf("path:%s is already restored with the older entry", entry.Path))
+			return nil
+		}
+		restored[key] = true
+		memberIdx := -1
+		for i, member := range common.GroupMembers {
+			if h.AppendSlash(member) == entry.BaseDir {
+				memberIdx = i
+				break
+			}
+		}
+		result := "ERROR_NOT_IN_BASEDIR"
+		if memberIdx < 0 {
+			h.Log("WARN", fmt.Sprintf("path:%s is recorded for %s, which is not in -b %v", entry.Path, entry.BaseDir, common.GroupMembers))
+		} else {
+			if common.CurrentMember != common.GroupMembers[memberIdx] {
+				useMember(memberIdx)
+			}
+			result = restoreJournalEntry(entry)
+		}
+		printOrSave(entry.Path+common.SEP+entry.Action+common.SEP+result, common.SaveToPointer)
+		return nil
+	})
+}
+
+func restoreJournalEntry(entry lib.JournalEntry) string {
+	if entry.Action == "repairProps" {
+		h.Log("WARN", fmt.Sprintf("path:%s was created by -repairProps, so nothing to restore (remove it manually if needed)", entry.Path))
+		return "SKIPPED_CREATED"
+	}
+	if common.DryRun {
+		h.Log("INFO", fmt.Sprintf("DRY-RUN: would restore path:%s (%s)", entry.Path, entry.Action))
+		return "DRY_RUN"
+	}
+	contents := entry.Contents
+	// ReadPath trims the contents, so adding the last new line back
+	if len(contents) > 0 && !strings.HasSuffix(contents, "\n") {
+		contents = contents + "\n"
+	}
+	if err := Client.WriteToPath(entry.Path, contents); err != nil {
+		h.Log("ERROR", fmt.Sprintf("Restoring path:%s failed with %s", entry.Path, err))
+		return "ERROR_WRITE"
+	}
+	if len(entry.Tags) > 0 {
+		tagClient, ok := bs_clients.AsTagClient(Client)
+		if !ok {
+			h.Log("WARN", fmt.Sprintf("path:%s has the tags in the journal, but %s does not support tags", entry.Path, common.BsType))
+			return "WARN_NO_TAG_SUPPORT"
+		}
+		for tagPath, tags := range entry.Tags {
+			if err := tagClient.SetTags(tagPath, tags); err != nil {
+				h.Log("ERROR", fmt.Sprintf("Restoring the tags of path:%s failed with %s", tagPath, err))
+				return "ERROR_SET_TAGS"
+			}
+		}
+	}
+	h.Log("INFO", fmt.Sprintf("Restored path:%s (%s)", entry.Path, entry.Action))
+	return "RESTORED"
+}
+
 func printLineFromPath(args bs_clients.PrintLineArgs) bool {
 	path := args.Path
 	blobInfo := args.BInfo
 	db := args.DB // for orphaned blob check only
 	saveToPointer := common.SaveToPointer
+	savePath := common.SaveToFile
 	var err error
 	if common.SavePerDir {
 		if len(args.SaveDir) == 0 || args.SaveDir == "." {
 			panic("SavePerDir is true but SaveDir is nil or '.'")
 		}
-		saveFile := filepath.Base(args.SaveDir) + ".tsv"
-		saveToPointer, err = os.OpenFile(filepath.Join(common.SaveToFile, saveFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			panic(err)
+		savePath = savePerDirPath(args.SaveDir)
+		if common.OutputFormat != "parquet" {
+			saveToPointer, err = os.OpenFile(savePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				panic(err)
+			}
+			defer saveToPointer.Close()
 		}
-		defer saveToPointer.Close()
 	}
 
 	// This function should control the all counters
@@ -773,6 +1253,7 @@ func printLineFromPath(args bs_clients.PrintLineArgs) bool {
 	}
 	// Incrementing the checked number counter *synchronously* (not sure if this causes some slowness)
 	atomic.AddInt64(&common.CheckedNum, 1)
+	lib.CountChecked(common.BsType)
 
 	//h.Log("DEBUG", fmt.Sprintf("Generating the output for '%s'", path))
 	output, skipReason := genOutput(path, blobInfo, db)
@@ -804,11 +1285,64 @@ func printLineFromPath(args bs_clients.PrintLineArgs) bool {
 	if len(output) > 0 {
 		//h.Log("DEBUG", fmt.Sprintf("Current output: '%s' for %s", output, path))
 		atomic.AddInt64(&common.TotalSize, blobInfo.Size)
-		printOrSave(output, saveToPointer)
+		lib.CountListed(common.BsType)
+		if Summary != nil {
+			addToSummary(path, blobInfo, output)
+		} else if common.OutputFormat == "parquet" {
+			saveParquet(output, savePath)
+		} else {
+			printOrSave(output, saveToPointer)
+		}
 	}
 	return true
 }
 
+// addToSummary accumulates the output line instead of printing
+func addToSummary(path string, blobInfo bs_clients.BlobInfo, output string) {
+	// PrintedNum is still used for -n
+	atomic.AddInt64(&common.PrintedNum, 1)
+	sortedProps := ""
+	if idx := slices.Index(common.OutputCols, "Properties"); idx >= 0 {
+		if cols := strings.Split(output, common.SEP); idx < len(cols) {
+			sortedProps = cols[idx]
+		}
+	}
+	Summary.Add(path, blobInfo.ModTime, blobInfo.Size, sortedProps)
+}
+
+// printSummary prints the summary report, or saves it as JSON if -s is given
+func printSummary() {
+	if len(common.SaveToFile) == 0 {
+		fmt.Println(Summary.Text())
+		return
+	}
+	report, err := Summary.Json()
+	if err == nil {
+		err = os.WriteFile(common.SaveToFile, []byte(report+"\n"), 0644)
+	}
+	if err != nil {
+		h.Log("ERROR", fmt.Sprintf("Saving the summary into %s failed with %s", common.SaveToFile, err.Error()))
+		return
+	}
+	h.Log("INFO", "Saved the summary into "+common.SaveToFile)
+}
+
+// savePerDirPath returns the file path for the sub directory when SavePerDir
+func savePerDirPath(saveDir string) string {
+	if common.OutputFormat == "parquet" {
+		// Each Parquet file is written from the beginning, so the name should be unique per sub directory and member
+		saveFile := strings.ReplaceAll(strings.Trim(lib.GetAfterContent(string(filepath.Separator)+saveDir), string(filepath.Separator)), string(filepath.Separator), "_")
+		if len(saveFile) == 0 {
+			saveFile = filepath.Base(saveDir)
+		}
+		if len(common.GroupMembers) > 1 {
+			saveFile = fmt.Sprintf("%s_%d", saveFile, slices.Index(common.GroupMembers, common.CurrentMember))
+		}
+		return filepath.Join(common.SaveToFile, saveFile+lib.PARQUET_EXT)
+	}
+	return filepath.Join(common.SaveToFile, filepath.Base(saveDir)+"."+common.OutputFormat)
+}
+
 func getContentsFromCache(path string) (string, error) {
 	if common.CacheSize > 0 {
 		valueInCache := h.CacheGetObj(path)
@@ -857,14 +1391,34 @@ func copyPropsBytesToBaseDir2(propPath string) (string, string) {
 		maybeCustomizedBytesPath := lib.GetPathWithoutExt(writingPath) + common.BYTES_EXT
 		errorCodeBytes := copyPathToBaseDir2(bytesPath, maybeCustomizedBytesPath)
 		if len(errorCodeBytes) > 0 && errorCodeBytes != "ALREADY_EXISTS" {
+			lib.CountCopyError(errorCodeBytes)
 			// write error should be already reported, so DEBUG
 			h.Log("DEBUG", fmt.Sprintf("copyPathToBaseDir2 completed with error. path:%s, errorCodeBytes:%s", bytesPath, errorCodeBytes))
 			// If Bytes failed to copy, no point of copying properties.
 			return errorCodeBytes, ""
 		}
+		if common.B2Verify {
+			errorCodeVerify := verifyCopiedBytes(propPath, bytesPath, maybeCustomizedBytesPath)
+			if len(errorCodeVerify) > 0 {
+				lib.CountCopyError(errorCodeVerify)
+				// Not copying the properties, so that the unverified .bytes is not used
+				return errorCodeVerify, ""
+			}
+		}
 	}
 
 	errorCode := copyPathToBaseDir2(propPath, writingPath)
+	if len(errorCode) > 0 && errorCode != "ALREADY_EXISTS" {
+		lib.CountCopyError(errorCode)
+	} else if BlobIdMapping != nil && propPath != maybeCustomizedPath {
+		// Both .properties and .bytes are copied with the new blob ID
+		oldBlobId := lib.ExtractBlobIdFromString(propPath)
+		newBlobId := lib.ExtractBlobIdFromString(writingPath)
+		if err := BlobIdMapping.Add(oldBlobId, newBlobId); err != nil {
+			h.Log("ERROR", fmt.Sprintf("Saving the blob ID mapping %s -> %s failed with %s", oldBlobId, newBlobId, err))
+			errorCode = "ERROR_MAPPING"
+		}
+	}
 	h.Log("DEBUG", fmt.Sprintf("copyPathToBaseDir2 completed for %s, errorCode:%s", propPath, errorCode))
 	return errorCode, writingPath
 }
@@ -947,7 +1501,7 @@ func copyPathToBaseDir2(path string, writingPath string) string {
 	reader := maybeReader.(io.ReadCloser)
 	defer reader.Close()
 
-	// TODO: this doesn't work with -bTo-NewBlobId because the path is different from the original one, so need to consider the customized path as well
+	// With -bTo-NewBlobId, writingPath already has the new blob ID (.bytes path is generated from the .properties one)
 	_, errC := io.Copy(writer, reader)
 	if errC != nil {
 		h.Log("ERROR", fmt.Sprintf("Copying data from path:%s to BaseDir2:%s failed with %s", path, common.BaseDir2, errC))
@@ -955,7 +1509,7 @@ func copyPathToBaseDir2(path string, writingPath string) string {
 	}
 
 	if !common.NoExtraChk {
-		toInfo, errD := Client.GetFileInfo(writingPath)
+		toInfo, errD := Client2.GetFileInfo(writingPath)
 		if errD != nil {
 			h.Log("ERROR", fmt.Sprintf("Getting destination file info for path:%s failed with %s", writingPath, errD))
 			return "ERROR_NO_DEST_INFO" + errSfx
@@ -964,32 +1518,102 @@ func copyPathToBaseDir2(path string, writingPath string) string {
 			h.Log("WARN", fmt.Sprintf("Size 0 after copying to %s to BaseDir2:%s", writingPath, common.BaseDir2))
 			return "WARN_ZERO_SIZE" + errSfx
 		}
-		// Currently not doing the below.
-		// Verifying the copied file size
-		/*
-			srcInfo, errS := Client.GetFileInfo(path)
-			if errS != nil {
-				h.Log("WARN", fmt.Sprintf("Getting source file info for path:%s failed with %s", path, errS))
-				return "WARN_NO_SRC_INFO" + errSfx
-			}
-			if srcInfo.Size != toInfo.Size {
-				h.Log("ERROR", fmt.Sprintf("Size mismatch after copying path:%s to BaseDir2:%s (srcSize:%d vs destSize:%d)", path, common.BaseDir2, srcInfo.Size, destInfo.Size))
-				return "ERROR_SIZE_MISMATCH" + errSfx
-			}
-		*/
+		// Comparing with the source size is done by verifyCopiedBytes (-bTo-Verify)
 	}
 
 	h.Log("INFO", fmt.Sprintf("Copied %s under %s", writingPath, common.BaseDir2))
 	return ""
 }
 
+// verifyCopiedBytes compares the size and the sha1 recorded in the .properties (and SHA-256 if B2VerifySha256) of the source and destination .bytes
+func verifyCopiedBytes(propPath string, bytesPath string, writingBytesPath string) string {
+	srcInfo, errS := Client.GetFileInfo(bytesPath)
+	if errS != nil {
+		h.Log("ERROR", fmt.Sprintf("Getting source file info for path:%s failed with %s", bytesPath, errS))
+		return "ERROR_NO_SRC_INFO_BYTES"
+	}
+	toInfo, errD := Client2.GetFileInfo(writingBytesPath)
+	if errD != nil {
+		h.Log("ERROR", fmt.Sprintf("Getting destination file info for path:%s failed with %s", writingBytesPath, errD))
+		return "ERROR_NO_DEST_INFO_BYTES"
+	}
+	if srcInfo.Size != toInfo.Size {
+		h.Log("ERROR", fmt.Sprintf("Size mismatch after copying path:%s to BaseDir2:%s (srcSize:%d vs destSize:%d)", bytesPath, common.BaseDir2, srcInfo.Size, toInfo.Size))
+		return "ERROR_SIZE_MISMATCH"
+	}
+
+	contents, _ := getContentsFromCache(propPath)
+	if len(contents) == 0 {
+		var err error
+		contents, err = Client.ReadPath(propPath)
+		if err != nil {
+			h.Log("ERROR", fmt.Sprintf("Reading path:%s for verifying failed with %s", propPath, err))
+			return "ERROR_READ_PROPS"
+		}
+	}
+	var algorithms []string
+	expectedSha1 := lib.GetSha1(contents)
+	if len(expectedSha1) > 0 {
+		algorithms = append(algorithms, "sha1")
+	} else {
+		h.Log("WARN", fmt.Sprintf("No sha1 in path:%s. Only the size is verified for %s", propPath, writingBytesPath))
+	}
+	if common.B2VerifySha256 {
+		algorithms = append(algorithms, "sha256")
+	}
+	if len(algorithms) == 0 {
+		return ""
+	}
+
+	toHashes, errH := hashPath(Client2, writingBytesPath, algorithms...)
+	if errH != nil {
+		h.Log("ERROR", fmt.Sprintf("Computing hash of path:%s in BaseDir2:%s failed with %s", writingBytesPath, common.BaseDir2, errH))
+		return "ERROR_VERIFY_READ"
+	}
+	if len(expectedSha1) > 0 && toHashes["sha1"] != expectedSha1 {
+		h.Log("ERROR", fmt.Sprintf("sha1 mismatch after copying path:%s to BaseDir2:%s (expected:%s vs dest:%s)", bytesPath, common.BaseDir2, expectedSha1, toHashes["sha1"]))
+		return "ERROR_HASH_MISMATCH"
+	}
+	if common.B2VerifySha256 {
+		srcHashes, errSH := hashPath(Client, bytesPath, "sha256")
+		if errSH != nil {
+			h.Log("ERROR", fmt.Sprintf("Computing hash of path:%s in BaseDir:%s failed with %s", bytesPath, common.BaseDir, errSH))
+			return "ERROR_VERIFY_READ"
+		}
+		if srcHashes["sha256"] != toHashes["sha256"] {
+			h.Log("ERROR", fmt.Sprintf("SHA-256 mismatch after copying path:%s to BaseDir2:%s (src:%s vs dest:%s)", bytesPath, common.BaseDir2, srcHashes["sha256"], toHashes["sha256"]))
+			return "ERROR_HASH_MISMATCH"
+		}
+	}
+	h.Log("DEBUG", fmt.Sprintf("Verified %s (size:%d, %v)", writingBytesPath, toInfo.Size, toHashes))
+	return ""
+}
+
+func hashPath(client bs_clients.Client, path string, algorithms ...string) (map[string]string, error) {
+	maybeReader, err := client.GetReader(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := maybeReader.(io.ReadCloser)
+	defer reader.Close()
+	hashes, _, err := lib.HashReader(reader, algorithms...)
+	return hashes, err
+}
+
 func printOrSave(line string, saveToPointer *os.File) {
 	// At this moment, excluding empty line and tab only line
 	if len(line) == 0 || line == common.SEP {
 		return
 	}
 
+	if common.OutputFormat == "parquet" {
+		saveParquet(line, common.SaveToFile)
+		return
+	}
 	atomic.AddInt64(&common.PrintedNum, 1)
+	if common.OutputFormat != "tsv" {
+		line = lib.FormatLine(line, common.OutputCols, common.OutputFormat)
+	}
 	if saveToPointer != nil {
 		//h.Log("INFO", saveToPointer.Name())
 		_, _ = fmt.Fprintln(saveToPointer, line)
@@ -998,16 +1622,45 @@ func printOrSave(line string, saveToPointer *os.File) {
 	_, _ = fmt.Println(line)
 }
 
-func listObjects(dir string, db *sql.DB) {
+// saveParquet writes the line into the Parquet file (savePath), which is kept open until closed by closeParquet
+func saveParquet(line string, savePath string) {
+	if len(line) == 0 || line == common.SEP {
+		return
+	}
+	atomic.AddInt64(&common.PrintedNum, 1)
+	if err := lib.WriteParquetLine(savePath, line, common.OutputCols); err != nil {
+		panic(err)
+	}
+}
+
+// closeParquet writes the footer of the Parquet file(s). If savePath is empty, all Parquet files are closed.
+func closeParquet(savePath string) {
+	if common.OutputFormat != "parquet" {
+		return
+	}
+	var err error
+	if len(savePath) == 0 {
+		err = lib.CloseAllParquet()
+	} else {
+		err = lib.CloseParquet(savePath)
+	}
+	if err != nil {
+		h.Log("ERROR", err.Error())
+	}
+}
+
+// listObjects lists the objects in the dir. Returns the error if the listing stopped in the middle.
+func listObjects(dir string, db *sql.DB, perLineFunc func(bs_clients.PrintLineArgs) bool) error {
 	startMs := time.Now().UnixMilli()
 	//h.Log("INFO", fmt.Sprintf("Listing objects from %s", dir))
-	subTtl := Client.ListObjects(dir, db, printLineFromPath)
+	subTtl, err := Client.ListObjects(dir, db, perLineFunc)
 	// Always log this elapsed time by using 0 thresholdMs if subTtl > 0
 	thresholdMs := common.SlowMS
 	if subTtl > 0 {
 		thresholdMs = int64(0)
 	}
 	h.Elapsed(startMs, fmt.Sprintf("Processed %d blobs from %s (current total: %d)", subTtl, dir, common.CheckedNum), thresholdMs)
+	return err
 }
 
 func checkBlobIdDetailFromDB(maybeBlobId string) interface{} {
@@ -1095,6 +1748,36 @@ func checkBlobIdDetailFromBS(maybeBlobId string) interface{} {
 	return nil
 }
 
+// getBlobToLocal downloads the .properties and .bytes files of the blob ID/ref/path into the local directory.
+// If the group blob store, the first member which has the .properties file is used.
+func getBlobToLocal(maybeBlobId string, localDir string) error {
+	basePath := lib.GenBlobPath(maybeBlobId, "")
+	if len(basePath) == 0 {
+		return errors.New("no blob ID found in " + maybeBlobId)
+	}
+	common.OutputCols = []string{"LocalPath"}
+	for i := range common.GroupMembers {
+		useMember(i)
+		blobPath := h.AppendSlash(common.ContentPath) + basePath
+		if _, err := Client.GetFileInfo(blobPath + common.PROP_EXT); err != nil {
+			h.Log("DEBUG", fmt.Sprintf("No %s%s in %s (error: %s)", blobPath, common.PROP_EXT, common.CurrentMember, err.Error()))
+			continue
+		}
+		for _, ext := range []string{common.PROP_EXT, common.BYTES_EXT} {
+			localPath := filepath.Join(localDir, filepath.Base(basePath)+ext)
+			if err := Client.GetPath(blobPath+ext, localPath); err != nil {
+				// .bytes may not exist (eg: deletion marker), so continuing
+				h.Log("WARN", fmt.Sprintf("Getting %s%s failed with %s", blobPath, ext, err.Error()))
+				continue
+			}
+			h.Log("INFO", fmt.Sprintf("Saved %s%s from %s", blobPath, ext, common.CurrentMember))
+			printOrSave(localPath, common.SaveToPointer)
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("%s%s does not exist in %v", basePath, common.PROP_EXT, common.GroupMembers))
+}
+
 func maybeCopyPathToBaseDir2(maybeSrcBlobPath string) interface{} {
 	if common.TopN > 0 && common.TopN <= common.PrintedNum {
 		h.Log("DEBUG", fmt.Sprintf("Printed %d >= %d", common.PrintedNum, common.TopN))
@@ -1226,25 +1909,12 @@ func genAssetBlobUnionQuery(columns string, afterWhere string, repos []string, f
 		formatRepoNames = getFormatsWithRepos(repos)
 	}
 
+	dialect := lib.GetDialect()
 	queries := make([]string, 0)
 	for actualFmt, actualRepos := range formatRepoNames {
-		repoIn := `'` + strings.Join(actualRepos, `','`) + `'`
-		q := "WITH r AS (select r.name, cr.repository_id from " + actualFmt + "_content_repository cr join repository r on r.id = cr.config_repository_id WHERE r.name IN (" + repoIn + ")) "
-		q = q + "SELECT r.name as repo_name, " + columns
-		q = q + " FROM " + actualFmt + "_asset_blob ab"
-		// NOTE: Due to the performance concern, NOT using LEFT JOIN even though this script may find orphaned blobs when Cleanup unused asset blob tasks aren't run yet.
-		q = q + " JOIN " + actualFmt + "_asset a USING (asset_blob_id) JOIN r USING (repository_id)"
-		q = q + " WHERE 1=1 " + afterWhere
-		queries = append(queries, q)
+		queries = append(queries, dialect.AssetBlobQuery(actualFmt, columns, actualRepos, afterWhere))
 	}
-
-	query := ""
-	if len(queries) == 1 {
-		query = queries[0]
-	} else if len(queries) > 1 {
-		query = "(" + strings.Join(queries, ") UNION ALL (") + ")"
-	}
-	return query
+	return dialect.UnionAll(queries)
 }
 
 func mayNeedUpdateBaseDir(baseDir string, pathFilter string, client bs_clients.Client) (string, string) {
@@ -1283,7 +1953,72 @@ func genSubDirs(baseDir string, pathFilter string, client bs_clients.Client) (ma
 	return matchingDirs, err
 }
 
-func isOrphanedBlob(contents string, blobId string, db *sql.DB) string {
+// loadBlobRefs streams all blob refs of the repositories in -qRepos or common.Repo2Fmt (filtered by -bsName) from the DB
+func loadBlobRefs(db *sql.DB) *lib.BlobRefSet {
+	startMs := time.Now().UnixMilli()
+	// Anything created after this time may be missing, so LoadedAt is set before the query
+	refs := lib.NewBlobRefSet(!common.NoExtraChk)
+	repoNames := common.QRepoNameList
+	if len(repoNames) == 0 {
+		for repoName := range common.Repo2Fmt {
+			repoNames = append(repoNames, repoName)
+		}
+	}
+	query := genAssetBlobUnionQuery("a.path, ab.blob_ref", "", repoNames, "")
+	if len(query) == 0 {
+		h.Log("WARN", "No repositories to load the blob refs for -dbBulk")
+		return refs
+	}
+	rows := lib.Query(query, db, 0)
+	if rows == nil {
+		panic("Loading the blob refs for -dbBulk failed with query: " + query)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var repoName, path, blobRef string
+		if err := rows.Scan(&repoName, &path, &blobRef); err != nil {
+			panic(err)
+		}
+		if !refs.Add(repoName, blobRef, path) {
+			h.Log("DEBUG", fmt.Sprintf("No blob ID in blob_ref:%s (repo:%s, path:%s)", blobRef, repoName, path))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+	h.Elapsed(startMs, fmt.Sprintf("Loaded %d blob IDs from %d repositories for -dbBulk", refs.Len(), len(repoNames)), 0)
+	return refs
+}
+
+// isOrphanedBlobInRefs checks the blob with BlobRefs (-dbBulk). If decided is false, the per blob query is needed.
+func isOrphanedBlobInRefs(repoName string, format string, blobName string, blobId string, modTime time.Time) (reason string, decided bool) {
+	if len(repoName) == 0 {
+		// No repo-name in the .properties, so same as the per blob check
+		return "", false
+	}
+	repoInDb, pathInDb, found := BlobRefs.Lookup(blobId)
+	if !found {
+		// The blob created (or modified) after loading may be in the DB
+		if modTime.IsZero() || modTime.After(BlobRefs.LoadedAt) {
+			return "", false
+		}
+		h.Log("WARN", fmt.Sprintf("Orphaned Blob Found:%s for repo:%s, format:%s", blobId, repoName, format))
+		return "ORPHAN:" + repoName + "|" + format, true
+	}
+	// In multiple repositories or in another repository, so the query against the repo-name is needed
+	if repoInDb != repoName {
+		return "", false
+	}
+	if !common.NoExtraChk {
+		blobName_non_espcaped := strings.ReplaceAll(blobName, `\`, "")
+		if len(blobName) == 0 || blobName_non_espcaped != pathInDb {
+			return "MISMATCH_NAME:" + blobName_non_espcaped + "|" + pathInDb, true
+		}
+	}
+	return "", true
+}
+
+func isOrphanedBlob(contents string, blobId string, modTime time.Time, db *sql.DB) string {
 	// Orphaned blob is the blob which is in the blob store but not in the DB
 	// UNION ALL query against many tables is slow. so if contents is given, using specific table of the repo-name.
 	repoName := lib.GetRepoName(contents)
@@ -1302,6 +2037,12 @@ func isOrphanedBlob(contents string, blobId string, db *sql.DB) string {
 		h.Log("WARN", fmt.Sprintf("Repository: %s does not exist in the database, so assuming %s as orphan", repoName, blobId))
 		return "ORPHAN:" + repoName + "|" + format + "(NO_REPO)"
 	}
+	if BlobRefs != nil {
+		if reason, decided := isOrphanedBlobInRefs(repoName, format, blobName, blobId, modTime); decided {
+			return reason
+		}
+		BlobRefs.CountFallback()
+	}
 	// If repoName is empty, still checks to get the details
 	var repoNames []string
 	if len(repoName) > 0 {
@@ -1401,13 +2142,76 @@ func main() {
 	log.SetFlags(log.Lmicroseconds)
 	log.SetPrefix(time.Now().Format("2006-01-02 15:04:05"))
 	setGlobals()
-	// As currently not supporting multiple blob store types, Client should be only one instance
-	Client = bs_clients.GetClient(common.BsType)
-	Client.SetClientNum(1)
+	if err := run(); err != nil {
+		h.Log("ERROR", err.Error())
+		os.Exit(1)
+	}
+}
+
+// lingerMetrics keeps the metrics server running for -metricsLinger, as the short job may finish before the next scrape
+func lingerMetrics(metricsAddr string) {
+	if common.MetricsLinger <= 0 {
+		return
+	}
+	h.Log("INFO", fmt.Sprintf("Keeping http://%s/metrics for %s", metricsAddr, common.MetricsLinger))
+	time.Sleep(common.MetricsLinger)
+}
+
+// run executes the mode decided by the flags.
+// Returning the error instead of os.Exit, so that the deferred functions (eg. the Parquet footer, the journal and the errors file) are completed.
+func run() error {
+	// Client is set in useMember(), and changed per member if the group blob store
+	if Client == nil {
+		Client = bs_clients.WithRetry(bs_clients.GetClient(common.BsType))
+		Client.SetClientNum(1)
+	}
 	if len(common.BaseDir2) > 0 {
-		Client2 = bs_clients.GetClient(common.BsType2)
+		Client2 = bs_clients.WithRetry(bs_clients.GetClient(common.BsType2))
 		Client2.SetClientNum(2)
 	}
+	if len(common.MetricsAddr) > 0 {
+		metricsAddr, err := lib.StartMetricsServer(common.MetricsAddr)
+		if err != nil {
+			return fmt.Errorf("starting the metrics server on %s failed with %s", common.MetricsAddr, err.Error())
+		}
+		// Deferring first, so that this runs after the other deferred functions
+		defer lingerMetrics(metricsAddr)
+	}
+	// Parquet file is not readable until the footer is written
+	defer closeParquet("")
+	if common.Summary {
+		Summary = lib.NewSummary()
+		defer printSummary()
+	}
+	// For the long-running job, the progress can be checked with `kill -USR1 <pid>`
+	stopProgress := lib.StartProgress(time.Now().UnixMilli(), time.Duration(common.ProgressSec)*time.Second)
+	defer stopProgress()
+	if len(common.JournalFile) > 0 {
+		var err error
+		Journal, err = lib.OpenJournal(common.JournalFile)
+		if err != nil {
+			panic(err)
+		}
+		defer Journal.Close()
+		h.Log("INFO", "Recording the original contents into the journal: "+common.JournalFile)
+	}
+	defer func() {
+		if errorsFile := lib.CloseErrorsFile(); len(errorsFile) > 0 {
+			h.Log("WARN", fmt.Sprintf("%d request(s) still failed after %d attempt(s). Saved into %s (can be re-run with -rF)", lib.ErrorsNum, common.RetryMax, errorsFile))
+		}
+	}()
+	if len(common.B2MappingFile) > 0 {
+		var err error
+		BlobIdMapping, err = lib.OpenBlobIdMapping(common.B2MappingFile, common.B2SqlFile, common.B2RepoName)
+		if err != nil {
+			panic(err)
+		}
+		defer BlobIdMapping.Close()
+		h.Log("INFO", "Saving the old -> new blob refs into: "+common.B2MappingFile)
+		if len(common.B2SqlFile) > 0 {
+			h.Log("INFO", "Generating the SQL to update blob_ref of "+common.B2RepoName+" into: "+common.B2SqlFile)
+		}
+	}
 	// Currently only one DB object ...
 	var db *sql.DB
 	if len(common.DbConnStr) > 0 {
@@ -1415,6 +2219,23 @@ func main() {
 		defer db.Close()
 	}
 
+	// Retrieving a single blob does not need other modes
+	if len(common.GetFile) > 0 {
+		if len(common.BaseDir) == 0 {
+			panic("-get requires -b")
+		}
+		return getBlobToLocal(common.GetFile, common.GetTo)
+	}
+
+	// Restoring from the journal does not need other modes
+	if len(common.UndoJournal) > 0 {
+		if err := undoFromJournal(common.UndoJournal); err != nil {
+			return err
+		}
+		h.Log("INFO", fmt.Sprintf("Completed. Journal entries: %d", common.CheckedNum))
+		return nil
+	}
+
 	// NOTE: when Query is set, BlobIdFile should be empty.
 	if len(common.Query) > 0 {
 		if len(common.BlobIDFIle) == 0 {
@@ -1442,8 +2263,21 @@ func main() {
 		lib.GetRows(common.Query, db, common.BlobIDFIle, 200)
 	}
 
+	if common.DbBulk {
+		BlobRefs = loadBlobRefs(db)
+		defer func() {
+			h.Log("INFO", fmt.Sprintf("Checked with the DB per blob (-dbBulk fallback): %d", atomic.LoadInt64(&BlobRefs.Fallbacks)))
+		}()
+	}
+
 	startMs := time.Now().UnixMilli()
 
+	if common.DbStream {
+		findDeadBlobsFromDb(db)
+		h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d)", common.PrintedNum, common.CheckedNum), 0)
+		return nil
+	}
+
 	// If the list of Blob IDs is provided, use it
 	if len(common.BlobIDFIle) > 0 {
 		// If Truth (src) is not set or Truth and BlobIDFile type are the same, reading this file as a source
@@ -1455,7 +2289,7 @@ func main() {
 			} else if common.BlobIDFIleType == "DB" && len(common.BaseDir) > 0 && len(common.BaseDir2) == 0 {
 				printHeader(common.SaveToPointer)
 				h.Log("INFO", fmt.Sprintf("checkBlobIdDetailFromBS: list=%s, conc=%d (mode=%s)", common.BlobIDFIle, common.Conc1, common.Truth))
-				_ = h.StreamLines(common.BlobIDFIle, common.Conc1, checkBlobIdDetailFromBS)
+				checkBlobIdsInMembers(common.BlobIDFIle)
 			} else if common.BlobIDFIleType == "DB" && len(common.BaseDir2) > 0 {
 				h.Log("INFO", fmt.Sprintf("Copying files from list=%s to %s, conc=%d", common.BlobIDFIle, common.BaseDir2, common.Conc1))
 				_ = h.StreamLines(common.BlobIDFIle, common.Conc1, maybeCopyPathToBaseDir2)
@@ -1463,51 +2297,753 @@ func main() {
 				h.Log("DEBUG", fmt.Sprintf("No action was taken for mode:%s path=%s (type:%s) as DbConnStr or BaseDir is missing", common.Truth, common.BlobIDFIle, common.BlobIDFIleType))
 			}
 			h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d), Size: %d bytes", common.PrintedNum, common.CheckedNum, common.TotalSize), 0)
-			return
+			return nil
 		} else if len(common.Truth) > 0 && len(common.BlobIDFIleType) > 0 && common.Truth != common.BlobIDFIleType {
-			panic("TODO: 'rF' is provided but 'rF' type:" + common.BlobIDFIleType + " does not match with 'src' type:" + common.Truth + ", so this file should be used to compare with the filelist result.")
-			// TODO: implement this. Read the -src and check against the -rF file
+			// The -rF file is (for example) yesterday's result, so comparing with the current -src
+			h.Log("INFO", fmt.Sprintf("Comparing list=%s (type:%s) with src:%s", common.BlobIDFIle, common.BlobIDFIleType, common.Truth))
+			compareBlobIdFileWithSrc(db)
+			h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d)", common.PrintedNum, common.CheckedNum), 0)
+			return nil
 		}
 		// TODO: what should we do when BlobIDFIleType is empty?
 		h.Log("INFO", fmt.Sprintf("No action was taken for path=%s (type:%s)", common.BlobIDFIle, common.BlobIDFIleType))
-		return
+		return nil
 	}
 
 	if len(common.BaseDir) > 0 {
 		printHeader(common.SaveToPointer)
-		// If the Blob ID file is not provided, run per directory
-		h.Log("INFO", fmt.Sprintf("Finding sub directories under '%s' with filter:%s, maxDepth:%d (may take while)...", common.ContentPath, common.Filter4Path, common.MaxDepth))
+		if len(common.CheckpointFile) > 0 {
+			var err error
+			Checkpoint, err = lib.OpenCheckpoint(common.CheckpointFile, common.Resume)
+			if err != nil {
+				panic(err)
+			}
+			if common.Resume {
+				h.Log("INFO", fmt.Sprintf("Resuming with %d completed sub directories in %s", Checkpoint.DoneCount(), common.CheckpointFile))
+			}
+		}
+		if len(common.S3Inventory) > 0 {
+			if err := listFromS3Inventory(common.S3Inventory, printLineFromPath); err != nil {
+				return fmt.Errorf("reading the S3 Inventory %s failed with %s", common.S3Inventory, err.Error())
+			}
+			h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d), Size: %d bytes", common.PrintedNum, common.CheckedNum, common.TotalSize), 0)
+			return nil
+		}
+		listAllMembers(printLineFromPath)
+		if Checkpoint != nil {
+			// Completed, so the checkpoint is no longer needed
+			_ = Checkpoint.Close(true)
+		}
+		// Always log this elapsed time by using 0 thresholdMs
+		h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d), Size: %d bytes", common.PrintedNum, common.CheckedNum, common.TotalSize), 0)
+	}
+	return nil
+}
+
+// memberDirs is the sub directories to list of one member, and NotCompSubDirs used for finding them
+type memberDirs struct {
+	subDirs        []string
+	notCompSubDirs bool
+}
+
+// listAllMembers finds the sub directories of all members first, so that DirsTotal (the progress ETA) covers all members, then lists per member
+func listAllMembers(perLineFunc func(bs_clients.PrintLineArgs) bool) {
+	notCompSubDirs := common.NotCompSubDirs
+	members := make([]memberDirs, len(common.GroupMembers))
+	for i := range common.GroupMembers {
+		useMember(i)
+		members[i] = findMemberDirs(notCompSubDirs)
+		atomic.AddInt64(&common.DirsTotal, int64(len(members[i].subDirs)))
+	}
+	for i := range common.GroupMembers {
+		if len(common.GroupMembers) > 1 {
+			useMember(i)
+		}
+		listMember(members[i], perLineFunc)
+	}
+}
+
+// findMemberDirs returns the sub directories to list under the current member (BaseDir), excluding the completed ones in the checkpoint
+func findMemberDirs(notCompSubDirs bool) memberDirs {
+	startMs := time.Now().UnixMilli()
+	// NotCompSubDirs may be changed by the previous member, so resetting
+	common.NotCompSubDirs = notCompSubDirs
+	// If the Blob ID file is not provided, run per directory
+	h.Log("INFO", fmt.Sprintf("Finding sub directories under '%s' with filter:%s, maxDepth:%d (may take while)...", common.ContentPath, common.Filter4Path, common.MaxDepth))
+
+	var subDirs []string
+	var err error
+
+	baseDir, pathFilter := mayNeedUpdateBaseDir(common.BaseDir, common.Filter4Path, Client)
+	if !common.NotCompSubDirs {
+		h.Log("DEBUG", fmt.Sprintf("Computing the sub directories under: %s ...", baseDir))
+		subDirs, err = genSubDirs(baseDir, pathFilter, Client)
+	}
+	if len(subDirs) == 0 {
+		h.Log("INFO", fmt.Sprintf("Walking the directory: %s ...", baseDir))
+		common.WalkRecursive = true
+		common.NotCompSubDirs = true
+		subDirs, err = Client.GetDirs(baseDir, pathFilter, common.MaxDepth)
+	}
+	if err != nil {
+		h.Log("ERROR", "Failed to list directories in "+common.ContentPath+" with filter: "+common.Filter4Path)
+		panic(err)
+	}
+	if common.NotCompSubDirs {
+		h.Elapsed(startMs, fmt.Sprintf("GetDirs got %d directories", len(subDirs)), 200)
+	}
+	if common.Debug2 {
+		h.Log("DEBUG", fmt.Sprintf("Matched sub directories: %v", subDirs))
+	}
+	if Checkpoint != nil && Checkpoint.DoneCount() > 0 {
+		notDoneDirs := make([]string, 0, len(subDirs))
+		for _, dir := range subDirs {
+			if !Checkpoint.IsDone(checkpointUnit(dir)) {
+				notDoneDirs = append(notDoneDirs, dir)
+			}
+		}
+		h.Log("INFO", fmt.Sprintf("Skipping %d completed sub directories (remaining: %d)", len(subDirs)-len(notDoneDirs), len(notDoneDirs)))
+		subDirs = notDoneDirs
+	}
+	if Checkpoint != nil && common.Resume {
+		removeNotDoneLines(subDirs)
+	}
+	return memberDirs{subDirs: subDirs, notCompSubDirs: common.NotCompSubDirs}
+}
+
+// listMember lists the objects under the current member (BaseDir) per sub directory
+func listMember(member memberDirs, perLineFunc func(bs_clients.PrintLineArgs) bool) {
+	startMs := time.Now().UnixMilli()
+	// NotCompSubDirs may be changed by finding the sub directories of other members, so restoring
+	common.NotCompSubDirs = member.notCompSubDirs
+	chunks := h.Chunk(member.subDirs, 1) // 1 is for spawning the Go routine per subDir.
+	runParallel(chunks, func(dir string, db *sql.DB) {
+		listErr := listObjects(dir, db, perLineFunc)
+		atomic.AddInt64(&common.DirsDone, 1)
+		if common.SavePerDir {
+			// No more lines for this sub directory
+			closeParquet(savePerDirPath(dir))
+		}
+		// If stopped by TopN or the listing error, the directory may not be completed
+		if listErr != nil {
+			h.Log("WARN", fmt.Sprintf("Not recording %s as completed, as listing failed with %s", dir, listErr.Error()))
+		} else if Checkpoint != nil && (common.TopN == 0 || common.PrintedNum < common.TopN) {
+			if err := Checkpoint.MarkDone(checkpointUnit(dir)); err != nil {
+				h.Log("WARN", fmt.Sprintf("Recording %s in %s failed with %s", dir, Checkpoint.Path, err.Error()))
+			}
+		}
+	}, common.Conc1)
+	if len(common.GroupMembers) > 1 {
+		h.Elapsed(startMs, fmt.Sprintf("Completed member: %s (current total listed: %d, checked: %d)", common.CurrentMember, common.PrintedNum, common.CheckedNum), 0)
+	}
+}
+
+// removeNotDoneLines removes the lines of the not completed sub directories from the output (-s) before listing them again with -resume.
+// Otherwise, the lines which were written before the interruption would be duplicated.
+func removeNotDoneLines(dirs []string) {
+	if len(dirs) == 0 || common.OutputFormat == "parquet" {
+		// Each Parquet file per sub directory is written from the beginning
+		return
+	}
+	dirsMap := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		dirsMap[strings.TrimRight(dir, "/"+string(filepath.Separator))] = true
+	}
+	member := ""
+	if len(common.GroupMembers) > 1 {
+		member = common.CurrentMember
+	}
+	outPaths := []string{common.SaveToFile}
+	if common.SavePerDir {
+		outPaths = make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+			if outPath := savePerDirPath(dir); !slices.Contains(outPaths, outPath) {
+				outPaths = append(outPaths, outPath)
+			}
+		}
+	}
+	for _, outPath := range outPaths {
+		removed, err := lib.RemoveLinesUnder(outPath, dirsMap, member, common.OutputFormat)
+		if err != nil {
+			panic("Removing the lines of the not completed sub directories from " + outPath + " failed with " + err.Error())
+		}
+		if removed > 0 {
+			h.Log("INFO", fmt.Sprintf("Removed %d lines of the not completed sub directories from %s", removed, outPath))
+		}
+	}
+}
+
+// listFromS3Inventory reads the objects from the S3 Inventory report instead of listing the bucket.
+// Only the keys under the content path and matching -p are passed to perLineFunc.
+func listFromS3Inventory(manifestPath string, perLineFunc func(bs_clients.PrintLineArgs) bool) error {
+	manifestData, err := readS3InventoryFile(manifestPath, "")
+	if err != nil {
+		return err
+	}
+	manifest, err := lib.ParseInventoryManifest(manifestData)
+	if err != nil {
+		return err
+	}
+	if manifest.SourceBucket != common.Container {
+		h.Log("WARN", fmt.Sprintf("The inventory is for the bucket %s but -b is %s", manifest.SourceBucket, common.Container))
+	}
+	var rxPath *regexp.Regexp
+	if len(common.Filter4Path) > 0 {
+		rxPath, err = regexp.Compile(common.Filter4Path)
+		if err != nil {
+			return err
+		}
+	}
+	h.Log("INFO", fmt.Sprintf("Reading %d %s file(s) of the S3 Inventory for s3://%s/%s with filter:%s", len(manifest.Files), manifest.FileFormat, manifest.SourceBucket, common.ContentPath, common.Filter4Path))
+	atomic.AddInt64(&common.DirsTotal, int64(len(manifest.Files)))
+
+	// Same concurrency as listing (-c * -c2), and one DB connection per goroutine
+	conc := max(common.Conc1*common.Conc2, 1)
+	rows := make(chan lib.InventoryRow, conc)
+	wg := sync.WaitGroup{}
+	for i := 0; i < conc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var db *sql.DB
+			if len(common.DbConnStr) > 0 {
+				db = lib.OpenDb(common.DbConnStr)
+				defer db.Close()
+			}
+			for row := range rows {
+				perLineFunc(bs_clients.PrintLineArgs{
+					Path:    row.Key,
+					BInfo:   s3InventoryBlobInfo(row),
+					DB:      db,
+					SaveDir: filepath.Dir(row.Key) + "/",
+				})
+			}
+		}()
+	}
+
+	errStopped := errors.New("reached to -n")
+	apply := func(row lib.InventoryRow) error {
+		if common.TopN > 0 && common.TopN <= common.PrintedNum {
+			return errStopped
+		}
+		if !strings.HasPrefix(row.Key, common.ContentPath) || (rxPath != nil && !rxPath.MatchString(row.Key)) {
+			return nil
+		}
+		rows <- row
+		return nil
+	}
+	for _, dataFile := range manifest.Files {
+		if err = readS3InventoryData(manifestPath, manifest, dataFile.Key, apply); err != nil {
+			break
+		}
+		atomic.AddInt64(&common.DirsDone, 1)
+	}
+	close(rows)
+	wg.Wait()
+	if errors.Is(err, errStopped) {
+		h.Log("INFO", fmt.Sprintf("Found %d and reached to %d", common.PrintedNum, common.TopN))
+		return nil
+	}
+	return err
+}
+
+// s3InventoryBlobInfo converts the inventory row. The inventory does not include the tags, and the mtime is optional, so using HEAD for those.
+func s3InventoryBlobInfo(row lib.InventoryRow) bs_clients.BlobInfo {
+	if row.LastModified.IsZero() || common.WithTags {
+		bi, err := Client.GetFileInfo(row.Key)
+		if err != nil {
+			h.Log("DEBUG", fmt.Sprintf("GetFileInfo for %s failed with %s", row.Key, err.Error()))
+			return bs_clients.BlobInfo{Path: row.Key, ModTime: row.LastModified, Size: row.Size, Error: true}
+		}
+		return bi
+	}
+	return bs_clients.BlobInfo{Path: row.Key, ModTime: row.LastModified, Size: row.Size}
+}
+
+// readS3InventoryFile reads the manifest or the data file from the local path or S3 (s3://bucket/key, or the key in the bucket)
+func readS3InventoryFile(path string, bucket string) ([]byte, error) {
+	reader, err := openS3InventoryFile(path, bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func openS3InventoryFile(path string, bucket string) (io.ReadCloser, error) {
+	if len(bucket) == 0 {
+		if !strings.HasPrefix(path, "s3://") {
+			return os.Open(path)
+		}
+		// Not using GetContainerAndPrefix as it trims the path after 'content'
+		bucketAndKey := strings.SplitN(strings.TrimPrefix(path, "s3://"), "/", 2)
+		if len(bucketAndKey) != 2 {
+			return nil, fmt.Errorf("invalid S3 URI: %s", path)
+		}
+		bucket, path = bucketAndKey[0], bucketAndKey[1]
+	}
+	return bs_clients.GetS3ObjectReader(bucket, path)
+}
 
-		var subDirs []string
+// readS3InventoryData reads one data file. If the manifest is local, the data file is looked for next to it (or ../data/) first.
+func readS3InventoryData(manifestPath string, manifest *lib.InventoryManifest, dataKey string, apply func(lib.InventoryRow) error) error {
+	startMs := time.Now().UnixMilli()
+	defer h.Elapsed(startMs, "Read the S3 Inventory data "+dataKey, 0)
+	localPath := ""
+	if !strings.HasPrefix(manifestPath, "s3://") {
+		manifestDir := filepath.Dir(manifestPath)
+		for _, maybePath := range []string{filepath.Join(manifestDir, filepath.Base(dataKey)), filepath.Join(filepath.Dir(manifestDir), "data", filepath.Base(dataKey))} {
+			if _, err := os.Stat(maybePath); err == nil {
+				localPath = maybePath
+				break
+			}
+		}
+	}
+	if !manifest.IsParquet() && !manifest.IsOrc() {
+		var reader io.ReadCloser
 		var err error
+		if len(localPath) > 0 {
+			reader, err = os.Open(localPath)
+		} else {
+			reader, err = openS3InventoryFile(dataKey, manifest.DestinationBucketName())
+		}
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		return lib.ReadInventoryCsv(reader, manifest.FileSchema, apply)
+	}
 
-		baseDir, pathFilter := mayNeedUpdateBaseDir(common.BaseDir, common.Filter4Path, Client)
-		if !common.NotCompSubDirs {
-			h.Log("DEBUG", fmt.Sprintf("Computing the sub directories under: %s ...", baseDir))
-			subDirs, err = genSubDirs(baseDir, pathFilter, Client)
+	// Parquet and ORC need the random access, so downloading into the temp file
+	if len(localPath) == 0 {
+		reader, err := openS3InventoryFile(dataKey, manifest.DestinationBucketName())
+		if err != nil {
+			return err
 		}
-		if len(subDirs) == 0 {
-			h.Log("INFO", fmt.Sprintf("Walking the directory: %s ...", baseDir))
-			common.WalkRecursive = true
-			common.NotCompSubDirs = true
-			subDirs, err = Client.GetDirs(baseDir, pathFilter, common.MaxDepth)
+		defer reader.Close()
+		tmpFile, err := os.CreateTemp("", "filelist2_inventory_*"+filepath.Ext(dataKey))
+		if err != nil {
+			return err
 		}
+		defer os.Remove(tmpFile.Name())
+		_, err = io.Copy(tmpFile, reader)
+		_ = tmpFile.Close()
 		if err != nil {
-			h.Log("ERROR", "Failed to list directories in "+common.ContentPath+" with filter: "+common.Filter4Path)
-			panic(err)
+			return err
 		}
-		if common.NotCompSubDirs {
-			h.Elapsed(startMs, fmt.Sprintf("GetDirs got %d directories", len(subDirs)), 200)
+		localPath = tmpFile.Name()
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if manifest.IsOrc() {
+		return lib.ReadInventoryOrc(f, apply)
+	}
+	return lib.ReadInventoryParquet(f, apply)
+}
+
+// checkpointUnit returns the line for the checkpoint file. The member is included for the group blob store.
+func checkpointUnit(dir string) string {
+	if len(common.GroupMembers) > 1 {
+		return common.CurrentMember + common.SEP + dir
+	}
+	return dir
+}
+
+// compareBlobIdFileWithSrc reports the set difference between the blob IDs in the -rF file and the -src (BS or DB)
+func compareBlobIdFileWithSrc(db *sql.DB) {
+	fileBlobIds, err := lib.ReadBlobIdsFromFile(common.BlobIDFIle)
+	if err != nil {
+		panic(err)
+	}
+	h.Log("INFO", fmt.Sprintf("Read %d blob IDs from %s", len(fileBlobIds), common.BlobIDFIle))
+	printColumns([]string{"BlobId", "Status", "Detail"}, common.SaveToPointer)
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	counts := make(map[string]int64)
+	report := func(blobId string, status string, detail string) {
+		mu.Lock()
+		counts[status]++
+		mu.Unlock()
+		printOrSave(blobId+common.SEP+status+common.SEP+detail, common.SaveToPointer)
+	}
+	// For the blob IDs in the src, IN_BOTH or missing in the other side (the type of -rF)
+	missingInFile := "MISSING_IN_DB"
+	missingInSrc := "MISSING_IN_BS"
+	if common.Truth == "DB" {
+		missingInFile, missingInSrc = missingInSrc, missingInFile
+	}
+	checkSrcBlobId := func(blobId string, detail string) {
+		atomic.AddInt64(&common.CheckedNum, 1)
+		mu.Lock()
+		_, inFile := fileBlobIds[blobId]
+		alreadySeen := seen[blobId]
+		seen[blobId] = true
+		mu.Unlock()
+		if alreadySeen {
+			return
 		}
-		if common.Debug2 {
-			h.Log("DEBUG", fmt.Sprintf("Matched sub directories: %v", subDirs))
+		if inFile {
+			report(blobId, "IN_BOTH", detail)
+		} else {
+			report(blobId, missingInFile, detail)
 		}
-		// Reset the start time for listing
-		startMs = time.Now().UnixMilli()
-		chunks := h.Chunk(subDirs, 1) // 1 is for spawning the Go routine per subDir.
-		runParallel(chunks, listObjects, common.Conc1)
-		// Always log this elapsed time by using 0 thresholdMs
-		h.Elapsed(startMs, fmt.Sprintf("Completed. Listed: %d (checked: %d), Size: %d bytes", common.PrintedNum, common.CheckedNum, common.TotalSize), 0)
 	}
-	return
+
+	if common.Truth == "BS" {
+		if len(common.BaseDir) == 0 {
+			panic("-src BS with -rFType DB requires -b")
+		}
+		listAllMembers(func(args bs_clients.PrintLineArgs) bool {
+			// .properties and .bytes have the same blob ID, so using .properties only
+			if !strings.HasSuffix(args.Path, common.PROP_EXT) {
+				return true
+			}
+			if common.RxFilter4FileName != nil && !common.RxFilter4FileName.MatchString(args.Path) {
+				return true
+			}
+			blobId := common.RxBlobId.FindString(lib.ExtractBlobIdFromString(args.Path))
+			if len(blobId) > 0 {
+				checkSrcBlobId(blobId, args.Path)
+			}
+			return true
+		})
+	} else {
+		if db == nil {
+			panic("-src DB with -rFType BS requires -db")
+		}
+		repoNames := common.QRepoNameList
+		if len(repoNames) == 0 {
+			repoNames = getReposByFormat("")
+		}
+		query := genAssetBlobUnionQuery("ab.blob_ref as blob_id", "", repoNames, "")
+		if len(query) == 0 {
+			panic("No repository found to generate the query (check -bsName or -qRepos)")
+		}
+		rows := lib.Query(query, db, 0)
+		if rows != nil {
+			for rows.Next() {
+				var repoName, blobRef string
+				if err = rows.Scan(&repoName, &blobRef); err != nil {
+					h.Log("WARN", "rows.Scan returned error: "+err.Error())
+					continue
+				}
+				blobId := common.RxBlobId.FindString(lib.ExtractBlobIdFromString(blobRef))
+				if len(blobId) > 0 {
+					checkSrcBlobId(blobId, repoName+"|"+blobRef)
+				}
+			}
+			_ = rows.Close()
+		}
+	}
+
+	// The blob IDs only in the -rF file. Sorting to output always in the same order
+	fileOnlyIds := make([]string, 0)
+	for blobId := range fileBlobIds {
+		if !seen[blobId] {
+			fileOnlyIds = append(fileOnlyIds, blobId)
+		}
+	}
+	sort.Strings(fileOnlyIds)
+	for _, blobId := range fileOnlyIds {
+		report(blobId, missingInSrc, fileBlobIds[blobId])
+	}
+	h.Log("INFO", fmt.Sprintf("IN_BOTH: %d, %s: %d, %s: %d", counts["IN_BOTH"], missingInFile, counts[missingInFile], missingInSrc, counts[missingInSrc]))
+}
+
+// blobStoreMember is the client and the content path of one (group) member, to use the members concurrently
+type blobStoreMember struct {
+	name        string
+	client      bs_clients.Client
+	contentPath string
+}
+
+// findDeadBlobsFromDb streams the asset blobs of the repositories in -qRepos or common.Repo2Fmt (filtered by -bsName) from the DB,
+// and outputs the ones which .properties or .bytes does not exist in the blob store (any member if the group blob store)
+func findDeadBlobsFromDb(db *sql.DB) {
+	repoNames := common.QRepoNameList
+	if len(repoNames) == 0 {
+		repoNames = getReposByFormat("")
+	}
+	columns := "a.path, ab.blob_ref"
+	if common.RepairProps {
+		columns += ", ab.blob_size, ab.content_type, ab.blob_created, ab.created_by, ab.created_by_ip, CAST(a.attributes AS VARCHAR) AS attributes"
+	}
+	query := genAssetBlobUnionQuery(columns, "", repoNames, "")
+	if len(query) == 0 {
+		panic("No repository found to generate the query (check -bsName or -qRepos)")
+	}
+	members := getBlobStoreMembers()
+	cols := []string{"RepoName", "AssetPath", "BlobRef", "Missing"}
+	if common.RepairProps {
+		cols = append(cols, "Repair")
+	}
+	printColumns(cols, common.SaveToPointer)
+
+	type assetBlob struct {
+		repoName string
+		path     string
+		blobRef  string
+		meta     *lib.PropsMeta // Only with -repairProps
+	}
+	assetBlobs := make(chan assetBlob, common.Conc1*2)
+	var deadNum int64
+	var wg sync.WaitGroup
+	for i := 0; i < common.Conc1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ab := range assetBlobs {
+				atomic.AddInt64(&common.CheckedNum, 1)
+				missing, bytesMember, bytesInfo := findMissingBlobFiles(ab.blobRef, members)
+				if len(missing) == 0 {
+					continue
+				}
+				// ERROR_CHECK is not a dead blob (unknown)
+				if !strings.HasPrefix(missing, "ERROR_CHECK") {
+					atomic.AddInt64(&deadNum, 1)
+					lib.CountDeadBlob()
+				}
+				vals := []string{ab.repoName, ab.path, ab.blobRef, missing}
+				if common.RepairProps {
+					repair := "NOT_REPAIRABLE"
+					if strings.HasPrefix(missing, "ERROR_CHECK") {
+						repair = "ERROR_CHECK"
+					} else if bytesMember != nil {
+						repair = repairProps(ab.blobRef, ab.meta, bytesMember, bytesInfo)
+					}
+					vals = append(vals, repair)
+				}
+				printOrSave(strings.Join(vals, common.SEP), common.SaveToPointer)
+			}
+		}()
+	}
+
+	err := lib.QueryWithCursor(query, db, 10000, func(rows *sql.Rows) bool {
+		var ab assetBlob
+		var err error
+		if common.RepairProps {
+			ab.meta, err = scanPropsMeta(rows, &ab.repoName, &ab.path, &ab.blobRef)
+		} else {
+			err = rows.Scan(&ab.repoName, &ab.path, &ab.blobRef)
+		}
+		if err != nil {
+			h.Log("WARN", "rows.Scan returned error: "+err.Error())
+			return true
+		}
+		assetBlobs <- ab
+		return common.TopN == 0 || atomic.LoadInt64(&common.PrintedNum) < common.TopN
+	})
+	close(assetBlobs)
+	wg.Wait()
+	if err != nil {
+		panic(err)
+	}
+	h.Log("INFO", fmt.Sprintf("Dead blobs: %d in %d repositories", deadNum, len(repoNames)))
+}
+
+// getBlobStoreMembers returns the client and the content path of each group member.
+// The clients can be used concurrently, as each client has own container (bucket) instead of common.Container and bs_clients.AzContainer, which useMember() replaces.
+func getBlobStoreMembers() []blobStoreMember {
+	members := make([]blobStoreMember, 0, len(common.GroupMembers))
+	for i := range common.GroupMembers {
+		useMember(i)
+		client := bs_clients.WithRetry(bs_clients.GetClientWithContainer(common.BsType, common.Container))
+		client.SetClientNum(1)
+		members = append(members, blobStoreMember{name: common.CurrentMember, client: client, contentPath: common.ContentPath})
+	}
+	return members
+}
+
+// findMissingBlobFiles checks the .properties and .bytes of the blob ref concurrently, and returns the DEAD_BLOB message if missing in all members.
+// If only the .properties is missing, the member and the BlobInfo of the .bytes are also returned (for -repairProps).
+// If any check failed with other than "not found" (eg. 403, 5xx, I/O error), returns ERROR_CHECK as the file may exist.
+func findMissingBlobFiles(blobRef string, members []blobStoreMember) (missing string, bytesMember *blobStoreMember, bytesInfo bs_clients.BlobInfo) {
+	basePath := lib.GenBlobPath(blobRef, "")
+	if len(basePath) == 0 {
+		h.Log("WARN", fmt.Sprintf("No blob ID in blob_ref:%s", blobRef))
+		return "DEAD_BLOB:invalid blob_ref", nil, bytesInfo
+	}
+	missing = "DEAD_BLOB:missing properties/bytes"
+	var checkErr error
+	for i, member := range members {
+		blobPath := h.AppendSlash(member.contentPath) + basePath
+		var bi bs_clients.BlobInfo
+		var bytesErr error
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bi, bytesErr = member.client.GetFileInfo(blobPath + common.BYTES_EXT)
+		}()
+		_, propsErr := member.client.GetFileInfo(blobPath + common.PROP_EXT)
+		wg.Wait()
+		if propsErr == nil && bytesErr == nil {
+			return "", nil, bytesInfo
+		}
+		h.Log("DEBUG", fmt.Sprintf("blob_ref:%s in member:%s properties error:%v, bytes error:%v", blobRef, member.name, propsErr, bytesErr))
+		for _, err := range []error{propsErr, bytesErr} {
+			if err != nil && checkErr == nil && !bs_clients.IsNotFound(member.client, err) {
+				checkErr = err
+			}
+		}
+		if propsErr == nil {
+			missing = "DEAD_BLOB:missing bytes"
+			bytesMember = nil
+		} else if bytesErr == nil && missing != "DEAD_BLOB:missing bytes" {
+			missing = "DEAD_BLOB:missing properties"
+			if bytesMember == nil {
+				bytesMember, bytesInfo = &members[i], bi
+			}
+		}
+	}
+	if checkErr != nil {
+		h.Log("WARN", fmt.Sprintf("Checking blob_ref:%s failed with %s", blobRef, checkErr.Error()))
+		return "ERROR_CHECK:" + strings.Join(strings.Fields(checkErr.Error()), " "), nil, bytesInfo
+	}
+	return missing, bytesMember, bytesInfo
+}
+
+// scanPropsMeta scans the row of findDeadBlobsFromDb with -repairProps
+func scanPropsMeta(rows *sql.Rows, repoName *string, path *string, blobRef *string) (*lib.PropsMeta, error) {
+	var size sql.NullInt64
+	var contentType, createdBy, createdByIp, attributes sql.NullString
+	var created interface{}
+	if err := rows.Scan(repoName, path, blobRef, &size, &contentType, &created, &createdBy, &createdByIp, &attributes); err != nil {
+		return nil, err
+	}
+	meta := &lib.PropsMeta{
+		RepoName:    *repoName,
+		BlobName:    *path,
+		Size:        size.Int64,
+		ContentType: contentType.String,
+		CreatedBy:   createdBy.String,
+		CreatedByIp: createdByIp.String,
+		Sha1:        lib.GetSha1FromAttributes(attributes.String),
+	}
+	if created != nil {
+		var err error
+		if meta.Created, err = lib.ParseDbTime(created); err != nil {
+			h.Log("DEBUG", fmt.Sprintf("blob_created of blob_ref:%s is not used (%s)", *blobRef, err.Error()))
+		}
+	}
+	return meta, nil
+}
+
+// repairProps writes the .properties regenerated from the DB into the member which has the .bytes. Returns the result for the Repair column.
+func repairProps(blobRef string, meta *lib.PropsMeta, member *blobStoreMember, bytesInfo bs_clients.BlobInfo) string {
+	if len(meta.Sha1) == 0 {
+		h.Log("WARN", fmt.Sprintf("No checksum.sha1 in the asset attributes for blob_ref:%s. Not repairing", blobRef))
+		return "SKIPPED_NO_SHA1"
+	}
+	if meta.Size != bytesInfo.Size {
+		h.Log("WARN", fmt.Sprintf("blob_size:%d in DB is different from the .bytes size:%d for blob_ref:%s. Not repairing", meta.Size, bytesInfo.Size, blobRef))
+		return "SKIPPED_SIZE_MISMATCH"
+	}
+	if meta.Created.IsZero() {
+		meta.Created = bytesInfo.ModTime
+	}
+	propsPath := h.AppendSlash(member.contentPath) + lib.GenBlobPath(blobRef, common.PROP_EXT)
+	// Checking again right before writing, as the .properties may have been created after the first check
+	if _, err := member.client.GetFileInfo(propsPath); err == nil {
+		h.Log("WARN", fmt.Sprintf("path:%s exists now. Not repairing", propsPath))
+		return "SKIPPED_EXISTS"
+	} else if !bs_clients.IsNotFound(member.client, err) {
+		h.Log("WARN", fmt.Sprintf("Checking path:%s failed with %s. Not repairing", propsPath, err.Error()))
+		return "ERROR_CHECK"
+	}
+	contents := lib.GenPropsContents(*meta, time.Now())
+	if common.DryRun {
+		h.Log("INFO", fmt.Sprintf("DRY-RUN: would create path:%s with %s", propsPath, lib.SortToSingleLine(contents)))
+		return "DRY_RUN"
+	}
+	// Not creating if can not be recorded
+	if Journal != nil {
+		if err := Journal.Record(lib.JournalEntry{Action: "repairProps", BaseDir: h.AppendSlash(member.name), Path: propsPath, Contents: contents}); err != nil {
+			h.Log("ERROR", fmt.Sprintf("Recording path:%s into the journal failed with %s. Not creating", propsPath, err))
+			return "ERROR_JOURNAL"
+		}
+	}
+	// Never overwriting the existing .properties (eg. with deleted=true) if the backend supports the create-only write
+	var err error
+	if writer, ok := bs_clients.AsCreateOnlyWriter(member.client); ok {
+		err = writer.CreateNewPath(propsPath, contents)
+	} else {
+		err = member.client.WriteToPath(propsPath, contents)
+	}
+	if errors.Is(err, bs_clients.ErrPathExists) {
+		h.Log("WARN", fmt.Sprintf("path:%s was created by someone else. Not repairing", propsPath))
+		return "SKIPPED_EXISTS"
+	}
+	if err != nil {
+		h.Log("ERROR", fmt.Sprintf("Creating path:%s failed with %s", propsPath, err))
+		return "ERROR_WRITE"
+	}
+	h.Log("INFO", fmt.Sprintf("Created path:%s for blob_ref:%s", propsPath, blobRef))
+	return "REPAIRED"
+}
+
+// checkBlobIdsInMembers checks the blob IDs in the file against the blob store (all members if the group blob store)
+func checkBlobIdsInMembers(blobIdFile string) {
+	if len(common.GroupMembers) < 2 {
+		_ = h.StreamLines(blobIdFile, common.Conc1, checkBlobIdDetailFromBS)
+		return
+	}
+	if common.Truth != "DB" {
+		// Not Dead blobs finder mode, so just output the found blobs per member
+		for i := range common.GroupMembers {
+			useMember(i)
+			_ = h.StreamLines(blobIdFile, common.Conc1, checkBlobIdDetailFromBS)
+		}
+		return
+	}
+	// Dead blobs finder mode: a blob ref may be in any member, so the blob IDs which exist in a member are removed
+	// from the list before checking the next member, and the last member reports the remaining ones as DEAD_BLOB.
+	srcFile := blobIdFile
+	for i := range common.GroupMembers {
+		useMember(i)
+		if i == len(common.GroupMembers)-1 {
+			common.CurrentMember = strings.Join(common.GroupMembers, ",")
+			_ = h.StreamLines(srcFile, common.Conc1, checkBlobIdDetailFromBS)
+			break
+		}
+		notFoundFile := filepath.Join(os.TempDir(), fmt.Sprintf("blob_ids_not_in_member_%d_%d.tsv", os.Getpid(), i))
+		notFoundPointer, err := os.OpenFile(notFoundFile, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			panic(err)
+		}
+		defer os.Remove(notFoundFile)
+		var mu sync.Mutex
+		_ = h.StreamLines(srcFile, common.Conc1, func(maybeBlobId string) interface{} {
+			if len(maybeBlobId) == 0 || existsInMember(maybeBlobId) {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = fmt.Fprintln(notFoundPointer, maybeBlobId)
+			return nil
+		})
+		_ = notFoundPointer.Close()
+		h.Log("INFO", fmt.Sprintf("Checked %s in member: %s", srcFile, common.CurrentMember))
+		srcFile = notFoundFile
+	}
+}
+
+// existsInMember returns true if both .properties and .bytes exist in the current member
+func existsInMember(maybeBlobId string) bool {
+	basePath := lib.GenBlobPath(maybeBlobId, "")
+	if len(basePath) == 0 {
+		return false
+	}
+	basePath = h.AppendSlash(common.ContentPath) + basePath
+	if _, err := Client.GetFileInfo(basePath + common.PROP_EXT); err != nil {
+		return false
+	}
+	if _, err := Client.GetFileInfo(basePath + common.BYTES_EXT); err != nil {
+		return false
+	}
+	return true
 }
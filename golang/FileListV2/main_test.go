@@ -1,11 +1,21 @@
 package main
 
 import (
+	"FileListV2/bs_clients"
 	"FileListV2/common"
+	"FileListV2/lib"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -93,3 +103,313 @@ func TestCheckEscapingBackSlashes(t *testing.T) {
 	assert.NotContains(t, blobName_non_espcaped, `\`, "Blob name should contain backslashes")
 	assert.Equal(t, blobName_non_espcaped, `/v2/-/blobs/sha256:6a0ac1617861a677b045b7ff88545213ec31c0ff08763195a70a4a5adda577bb`, "Blob name should match the expected value")
 }
+
+func TestShouldSkipThisContents_RxNot_CombinesWithInclAndExcl(t *testing.T) {
+	defer func() {
+		common.RxIncl = nil
+		common.RxExcl = nil
+		common.RxNot = nil
+	}()
+	withType := "@BlobStore.blob-name=/test.txt,@BlobStore.content-type=text/plain,@Bucket.repo-name=raw-hosted,size=11"
+	withoutType := "@BlobStore.blob-name=/test.txt,@Bucket.repo-name=raw-hosted,size=11"
+	common.RxNot = regexp.MustCompile("BlobStore.content-type")
+	assert.Error(t, shouldSkipThisContents(withType))
+	assert.NoError(t, shouldSkipThisContents(withoutType))
+
+	common.RxIncl = regexp.MustCompile("repo-name=maven-releases")
+	assert.Error(t, shouldSkipThisContents(withoutType))
+	common.RxIncl = regexp.MustCompile("repo-name=raw-hosted")
+	assert.NoError(t, shouldSkipThisContents(withoutType))
+
+	common.RxExcl = regexp.MustCompile("blob-name=/test.txt")
+	assert.Error(t, shouldSkipThisContents(withoutType))
+}
+
+func TestShouldSkipBecauseOfBytes_TextAndBinary(t *testing.T) {
+	Client = &bs_clients.FileClient{}
+	defer func() {
+		common.RxInclBytes = nil
+		common.RxExclBytes = nil
+	}()
+	dir := t.TempDir()
+	textProps := filepath.Join(dir, "00000000-0000-0000-0000-000000000001.properties")
+	_ = os.WriteFile(lib.GetPathWithoutExt(textProps)+common.BYTES_EXT, []byte("<metadata><password>secret</password></metadata>"), 0644)
+	zipProps := filepath.Join(dir, "00000000-0000-0000-0000-000000000002.properties")
+	_ = os.WriteFile(lib.GetPathWithoutExt(zipProps)+common.BYTES_EXT, []byte("PK\x03\x04password"), 0644)
+
+	common.RxInclBytes = regexp.MustCompile("<password>")
+	assert.NoError(t, shouldSkipBecauseOfBytes(textProps))
+	assert.Error(t, shouldSkipBecauseOfBytes(zipProps), "binary archives should not be checked")
+	assert.Error(t, shouldSkipBecauseOfBytes(filepath.Join(dir, "missing.properties")))
+
+	common.RxInclBytes = nil
+	common.RxExclBytes = regexp.MustCompile("<password>")
+	assert.Error(t, shouldSkipBecauseOfBytes(textProps))
+	// Binary archives are not listed as "does not contain" either
+	assert.Error(t, shouldSkipBecauseOfBytes(zipProps))
+	common.RxExclBytes = regexp.MustCompile("<username>")
+	assert.NoError(t, shouldSkipBecauseOfBytes(textProps))
+	assert.Error(t, shouldSkipBecauseOfBytes(zipProps))
+
+	// Only the first BytesRxMaxSize bytes are checked
+	common.BytesRxMaxSize = 10
+	defer func() { common.BytesRxMaxSize = 32768 }()
+	assert.NoError(t, shouldSkipBecauseOfBytes(textProps))
+}
+
+func TestGetBlobToLocal_BlobRef_DownloadsPropertiesAndBytes(t *testing.T) {
+	baseDir := t.TempDir()
+	localDir := t.TempDir()
+	blobRef := "default@6c1d3423-ecbc-4c52-a0fe-01a45a12883a@2025-08-14T02:44"
+	blobPath := filepath.Join(baseDir, common.CONTENT, lib.GenBlobPath(blobRef, ""))
+	_ = os.MkdirAll(filepath.Dir(blobPath), 0755)
+	_ = os.WriteFile(blobPath+common.PROP_EXT, []byte("@BlobStore.blob-name=/test.txt"), 0644)
+	_ = os.WriteFile(blobPath+common.BYTES_EXT, []byte("test"), 0644)
+	common.GroupMembers = []string{baseDir}
+	defer func() { common.GroupMembers = nil }()
+
+	err := getBlobToLocal(blobRef, localDir)
+	assert.NoError(t, err)
+	contents, err := os.ReadFile(filepath.Join(localDir, "6c1d3423-ecbc-4c52-a0fe-01a45a12883a"+common.BYTES_EXT))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", string(contents))
+	assert.FileExists(t, filepath.Join(localDir, "6c1d3423-ecbc-4c52-a0fe-01a45a12883a"+common.PROP_EXT))
+
+	err = getBlobToLocal("00000000-0000-0000-0000-000000000000", localDir)
+	assert.Error(t, err)
+}
+
+// forbiddenPropsClient fails the .properties check with other than "not found" (eg. 403)
+type forbiddenPropsClient struct {
+	bs_clients.FileClient
+}
+
+func (c *forbiddenPropsClient) GetFileInfo(path string) (bs_clients.BlobInfo, error) {
+	if strings.HasSuffix(path, common.PROP_EXT) {
+		return bs_clients.BlobInfo{Error: true}, os.ErrPermission
+	}
+	return c.FileClient.GetFileInfo(path)
+}
+
+func TestFindMissingBlobFiles_CheckError_NotRepairable(t *testing.T) {
+	baseDir := t.TempDir()
+	blobRef := "default@6c1d3423-ecbc-4c52-a0fe-01a45a12883a"
+	blobPath := filepath.Join(baseDir, common.CONTENT, lib.GenBlobPath(blobRef, ""))
+	_ = os.MkdirAll(filepath.Dir(blobPath), 0755)
+	_ = os.WriteFile(blobPath+common.BYTES_EXT, []byte("test"), 0644)
+	contentPath := filepath.Join(baseDir, common.CONTENT)
+
+	members := []blobStoreMember{{name: baseDir, client: bs_clients.WithRetry(&bs_clients.FileClient{}), contentPath: contentPath}}
+	missing, bytesMember, bytesInfo := findMissingBlobFiles(blobRef, members)
+	assert.Equal(t, "DEAD_BLOB:missing properties", missing)
+	assert.NotNil(t, bytesMember)
+	assert.Equal(t, int64(4), bytesInfo.Size)
+
+	members = []blobStoreMember{{name: baseDir, client: &forbiddenPropsClient{}, contentPath: contentPath}}
+	missing, bytesMember, _ = findMissingBlobFiles(blobRef, members)
+	assert.True(t, strings.HasPrefix(missing, "ERROR_CHECK:"), missing)
+	assert.Nil(t, bytesMember)
+}
+
+func TestRepairProps_PropertiesExists_NotOverwritten(t *testing.T) {
+	baseDir := t.TempDir()
+	blobRef := "default@6c1d3423-ecbc-4c52-a0fe-01a45a12883a"
+	blobPath := filepath.Join(baseDir, common.CONTENT, lib.GenBlobPath(blobRef, ""))
+	_ = os.MkdirAll(filepath.Dir(blobPath), 0755)
+	_ = os.WriteFile(blobPath+common.BYTES_EXT, []byte("test"), 0644)
+	member := &blobStoreMember{name: baseDir, client: bs_clients.WithRetry(&bs_clients.FileClient{}), contentPath: filepath.Join(baseDir, common.CONTENT)}
+	meta := &lib.PropsMeta{RepoName: "raw-hosted", BlobName: "/test.txt", Size: 4, Sha1: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"}
+	bytesInfo := bs_clients.BlobInfo{Size: 4}
+
+	// Created by someone else after the check, so not overwriting (eg. deleted=true)
+	_ = os.WriteFile(blobPath+common.PROP_EXT, []byte("deleted=true"), 0644)
+	assert.Equal(t, "SKIPPED_EXISTS", repairProps(blobRef, meta, member, bytesInfo))
+	contents, _ := os.ReadFile(blobPath + common.PROP_EXT)
+	assert.Equal(t, "deleted=true", string(contents))
+
+	member.client = &forbiddenPropsClient{}
+	assert.Equal(t, "ERROR_CHECK", repairProps(blobRef, meta, member, bytesInfo))
+
+	_ = os.Remove(blobPath + common.PROP_EXT)
+	member.client = bs_clients.WithRetry(&bs_clients.FileClient{})
+	assert.Equal(t, "REPAIRED", repairProps(blobRef, meta, member, bytesInfo))
+	contents, _ = os.ReadFile(blobPath + common.PROP_EXT)
+	assert.Contains(t, string(contents), "sha1=a94a8fe5ccb19ba61c4c0873d391e987982fbbd3")
+}
+
+func TestFindMissingBlobFiles_MembersInDifferentBuckets_ChecksOwnBucket(t *testing.T) {
+	blobRefA := "default@6c1d3423-ecbc-4c52-a0fe-01a45a12883a"
+	blobRefB := "default@11111111-2222-3333-4444-555555555555"
+	// bucket-a has only blobRefA, bucket-b has only blobRefB
+	exists := map[string]bool{
+		"/bucket-a/pa/content/" + lib.GenBlobPath(blobRefA, common.PROP_EXT):  true,
+		"/bucket-a/pa/content/" + lib.GenBlobPath(blobRefA, common.BYTES_EXT): true,
+		"/bucket-b/pb/content/" + lib.GenBlobPath(blobRefB, common.PROP_EXT):  true,
+		"/bucket-b/pb/content/" + lib.GenBlobPath(blobRefB, common.BYTES_EXT): true,
+	}
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[strings.SplitN(r.URL.Path, "/", 3)[1]] = true
+		mu.Unlock()
+		if !exists[r.URL.Path] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "4")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	origS3Api := bs_clients.S3Api
+	bs_clients.S3Api = s3.New(s3.Options{BaseEndpoint: aws.String(srv.URL), Region: "us-east-1", UsePathStyle: true, Credentials: aws.AnonymousCredentials{}})
+	defer func() {
+		bs_clients.S3Api = origS3Api
+		common.GroupMembers = nil
+		common.BaseDir, common.BsType, common.Container, common.Prefix, common.ContentPath = "", "", "", "", ""
+	}()
+
+	common.GroupMembers = []string{"s3://bucket-a/pa", "s3://bucket-b/pb"}
+	members := getBlobStoreMembers()
+	// The global container is the last member's, but each client should use own bucket
+	assert.Equal(t, "bucket-b", common.Container)
+	for _, blobRef := range []string{blobRefA, blobRefB} {
+		missing, _, _ := findMissingBlobFiles(blobRef, members)
+		assert.Empty(t, missing, blobRef)
+	}
+	missing, _, _ := findMissingBlobFiles("default@99999999-2222-3333-4444-555555555555", members)
+	assert.Equal(t, "DEAD_BLOB:missing properties/bytes", missing)
+	assert.True(t, requested["bucket-a"])
+	assert.True(t, requested["bucket-b"])
+}
+
+// taggedFileClient keeps the tags in memory, and the write drops the tags like S3 PutObject
+type taggedFileClient struct {
+	bs_clients.FileClient
+	tags map[string]map[string]string
+}
+
+func (c *taggedFileClient) GetTags(path string) (map[string]string, error) {
+	return c.tags[path], nil
+}
+
+func (c *taggedFileClient) SetTags(path string, tags map[string]string) error {
+	c.tags[path] = tags
+	return nil
+}
+
+func (c *taggedFileClient) WriteToPath(path string, contents string) error {
+	delete(c.tags, path)
+	return c.FileClient.WriteToPath(path, contents)
+}
+
+func TestUndoFromJournal_WStrOnTaggedObject_RestoresContentsAndTags(t *testing.T) {
+	baseDir := t.TempDir()
+	propsPath := filepath.Join(baseDir, common.CONTENT, lib.GenBlobPath("6c1d3423-ecbc-4c52-a0fe-01a45a12883a", common.PROP_EXT))
+	bytesPath := lib.GetPathWithoutExt(propsPath) + common.BYTES_EXT
+	_ = os.MkdirAll(filepath.Dir(propsPath), 0755)
+	_ = os.WriteFile(propsPath, []byte("size=4\n"), 0644)
+	client := &taggedFileClient{tags: map[string]map[string]string{
+		propsPath: {"deleted": "false"},
+		bytesPath: {"deleted": "false"},
+	}}
+	origClient := Client
+	Client = client
+	common.GroupMembers = []string{baseDir}
+	common.CurrentMember = baseDir
+	common.BaseDir = baseDir + string(filepath.Separator)
+	journalPath := filepath.Join(t.TempDir(), "journal.jsonl")
+	var err error
+	Journal, err = lib.OpenJournal(journalPath)
+	assert.NoError(t, err)
+	defer func() {
+		Client, Journal = origClient, nil
+		common.GroupMembers, common.CurrentMember, common.BaseDir = nil, "", ""
+	}()
+
+	assert.True(t, appendStr("deleted=true", "size=4", propsPath))
+	assert.NoError(t, Journal.Close())
+	_, found := client.tags[propsPath]
+	assert.False(t, found)
+
+	assert.NoError(t, undoFromJournal(journalPath))
+	contents, _ := os.ReadFile(propsPath)
+	assert.Equal(t, "size=4\n", string(contents))
+	assert.Equal(t, map[string]string{"deleted": "false"}, client.tags[propsPath])
+	assert.Equal(t, map[string]string{"deleted": "false"}, client.tags[bytesPath])
+}
+
+func TestCopyPropsBytesToBaseDir2_NewBlobId_PropertiesAndBytesShareNewId(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	oldBlobId := "6c1d3423-ecbc-4c52-a0fe-01a45a12883a"
+	propsPath := filepath.Join(srcDir, common.CONTENT, lib.GenBlobPath(oldBlobId, common.PROP_EXT))
+	_ = os.MkdirAll(filepath.Dir(propsPath), 0755)
+	_ = os.WriteFile(propsPath, []byte("@Bucket.repo-name=raw-hosted\nsize=4\n"), 0644)
+	_ = os.WriteFile(lib.GetPathWithoutExt(propsPath)+common.BYTES_EXT, []byte("test"), 0644)
+	origClient := Client
+	Client, Client2 = &bs_clients.FileClient{}, &bs_clients.FileClient{}
+	common.BaseDir2, common.ContentPath2, common.B2NewBlobId = dstDir, filepath.Join(dstDir, common.CONTENT), true
+	var err error
+	mappingPath := filepath.Join(t.TempDir(), "mapping.tsv")
+	BlobIdMapping, err = lib.OpenBlobIdMapping(mappingPath, "", "")
+	assert.NoError(t, err)
+	defer func() {
+		Client, Client2, BlobIdMapping = origClient, nil, nil
+		common.BaseDir2, common.ContentPath2, common.B2NewBlobId = "", "", false
+	}()
+
+	errorCode, writingPath := copyPropsBytesToBaseDir2(propsPath)
+	assert.Empty(t, errorCode)
+	newBlobId := lib.ExtractBlobIdFromString(writingPath)
+	assert.NotEmpty(t, newBlobId)
+	assert.NotEqual(t, oldBlobId, newBlobId)
+	// Both are in the vol-NN/chap-NN of the new blob ID
+	assert.Equal(t, filepath.Join(dstDir, common.CONTENT, lib.GenBlobPath(newBlobId, common.PROP_EXT)), writingPath)
+	props, _ := os.ReadFile(writingPath)
+	assert.Equal(t, "@Bucket.repo-name=raw-hosted\nsize=4\n", string(props))
+	bytes, _ := os.ReadFile(filepath.Join(dstDir, common.CONTENT, lib.GenBlobPath(newBlobId, common.BYTES_EXT)))
+	assert.Equal(t, "test", string(bytes))
+
+	assert.NoError(t, BlobIdMapping.Close())
+	mapping, _ := os.ReadFile(mappingPath)
+	assert.Equal(t, "OldBlobId\tNewBlobId\n"+oldBlobId+"\t"+newBlobId+"\n", string(mapping))
+}
+
+func TestListAllMembers_TwoMembers_DirsTotalIncludesAllMembers(t *testing.T) {
+	member1, member2 := t.TempDir(), t.TempDir()
+	for i, blobId := range []string{"11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222", "33333333-3333-3333-3333-333333333333"} {
+		baseDir := member2
+		if i == 0 {
+			baseDir = member1
+		}
+		propsPath := filepath.Join(baseDir, common.CONTENT, lib.GenBlobPath(blobId, common.PROP_EXT))
+		_ = os.MkdirAll(filepath.Dir(propsPath), 0755)
+		_ = os.WriteFile(propsPath, []byte("size=4"), 0644)
+	}
+	common.GroupMembers, common.Conc1 = []string{member1, member2}, 2
+	defer func() {
+		common.GroupMembers, common.CurrentMember, common.BaseDir, common.ContentPath = nil, "", "", ""
+		common.DirsTotal, common.DirsDone, common.Conc1 = 0, 0, 0
+		Client = nil
+	}()
+
+	var dirsTotalAtFirst int64 = -1
+	var listed []string
+	var mu sync.Mutex
+	listAllMembers(func(args bs_clients.PrintLineArgs) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if dirsTotalAtFirst < 0 {
+			dirsTotalAtFirst = atomic.LoadInt64(&common.DirsTotal)
+		}
+		listed = append(listed, args.Path)
+		return true
+	})
+	// The sub directories of the second member are counted before listing the first member
+	assert.Equal(t, common.DirsTotal, dirsTotalAtFirst)
+	assert.Equal(t, common.DirsTotal, common.DirsDone)
+	// 47 chapters per vol-NN directory, and the second member has two
+	assert.Equal(t, int64(47*3), common.DirsTotal)
+	assert.Len(t, listed, 3)
+}